@@ -0,0 +1,30 @@
+// Aggressive dead code elimination
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/adce"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func main() {
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	for i, function := range prog.Functions {
+		if prog.Functions[i], err = adce.ADCE(function); err != nil {
+			fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := utils.PrintProgram(prog); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
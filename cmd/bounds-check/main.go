@@ -0,0 +1,39 @@
+// Reports pointer arithmetic that is sure to leave its allocation
+//
+// Usage: bounds-check [-maybe]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/intervals"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func main() {
+	maybe := flag.Bool("maybe", false, "also report pointer arithmetic that only might leave its allocation")
+	flag.Parse()
+
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, function := range prog.Functions {
+		problems, err := intervals.Check(function)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("@%s:\n", function.Name)
+		for _, problem := range problems {
+			if !problem.Definite && !*maybe {
+				continue
+			}
+			fmt.Printf("  %s\n", problem)
+		}
+	}
+}
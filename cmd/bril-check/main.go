@@ -0,0 +1,29 @@
+// Reports every way a program isn't well formed, the exit code is 1 if there
+// is anything to report
+//
+// Usage: bril-check
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/validate"
+)
+
+func main() {
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	diagnostics := validate.Program(prog)
+	for _, d := range diagnostics {
+		fmt.Println(d)
+	}
+	if len(diagnostics) != 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,22 @@
+// Converts Bril JSON read from STDIN into the textual format
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/text"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func main() {
+	prog, err := utils.ReadProgram()
+	if err == nil {
+		err = text.Print(os.Stdout, prog)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
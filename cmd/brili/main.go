@@ -0,0 +1,40 @@
+// Bril interpreter
+//
+// Usage: brili [-p] [main args...] < program.json
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/interp"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func main() {
+	profile := flag.Bool("p", false, "print the number of dynamic instructions executed to stderr")
+	flag.Parse()
+
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	count, err := interp.Run(prog, flag.Args(), out)
+	// Flush before reporting any error so output that happened before
+	// the error is not lost.
+	out.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(2)
+	}
+
+	if *profile {
+		fmt.Fprintf(os.Stderr, "total_dyn_inst: %d\n", count)
+	}
+}
@@ -1,14 +1,29 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
 )
 
 func main() {
-	prog := utils.ReadProgram()
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
 
-	namesInOrder, nameToBlock := utils.BasicBlocks(prog.Functions[0])
-	cfg := utils.CFG(namesInOrder, nameToBlock)
+	var graphs []utils.FunctionGraph
+	for _, function := range prog.Functions {
+		namesInOrder, nameToBlock := utils.BasicBlocks(function)
+		cfg, err := utils.CFG(namesInOrder, nameToBlock)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+			os.Exit(1)
+		}
+		graphs = append(graphs, utils.FunctionGraph{Name: function.Name, NamesInOrder: namesInOrder, Graph: cfg})
+	}
 
-	utils.OutputDot(namesInOrder, cfg)
+	utils.OutputDot(graphs)
 }
@@ -0,0 +1,75 @@
+// Use-def and def-use chains
+//
+// Usage: chains use-def|def-use
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/chains"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func outputUseDef(c chains.Chains) {
+	for _, u := range c.Uses {
+		var defs []string
+		for _, d := range c.UseDef[u] {
+			defs = append(defs, d.String())
+		}
+		if len(defs) == 0 {
+			defs = []string{"undefined"}
+		}
+		fmt.Printf("  %s@%s:%d <- %s\n", u.Var, u.Block, u.Index, strings.Join(defs, ", "))
+	}
+}
+
+func outputDefUse(c chains.Chains) {
+	for _, d := range c.Defs {
+		var uses []string
+		for _, u := range c.DefUse[d] {
+			uses = append(uses, fmt.Sprintf("%s:%d", u.Block, u.Index))
+		}
+		if len(uses) == 0 {
+			uses = []string{"dead"}
+		}
+		fmt.Printf("  %s -> %s\n", d, strings.Join(uses, ", "))
+	}
+}
+
+func main() {
+	args := os.Args[1:]
+
+	if len(args) != 1 {
+		println("usage: chains use-def|def-use")
+		os.Exit(1)
+	}
+
+	var output func(c chains.Chains)
+	switch args[0] {
+	case "use-def":
+		output = outputUseDef
+	case "def-use":
+		output = outputDefUse
+	default:
+		println("unknown command")
+		os.Exit(1)
+	}
+
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, function := range prog.Functions {
+		c, err := chains.Build(function)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("@%s:\n", function.Name)
+		output(c)
+	}
+}
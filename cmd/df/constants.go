@@ -0,0 +1,65 @@
+package main
+
+import (
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	dfutils "aaronstgeorge.com/self-guided-cs-1620/pkg/df/utils"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ops"
+)
+
+func folded(_ string, instructions []models.Instruction, in lattice.ConstantLattice) lattice.ConstantLattice {
+	out := in.Copy()
+	for _, inst := range instructions {
+		if inst.Dest != nil {
+			out.Vars[*inst.Dest] = evaluate(inst, out)
+		}
+	}
+	return out
+}
+
+// evaluate - what is known about the result of inst given what is known
+// about its arguments
+func evaluate(inst models.Instruction, env lattice.ConstantLattice) lattice.Constant {
+	if *inst.Op == "const" {
+		v, err := ops.Literal(inst)
+		if err != nil {
+			return lattice.Constant{State: lattice.Bottom}
+		}
+		return lattice.Constant{State: lattice.Known, Value: v}
+	}
+	if !ops.Pure.Contains(*inst.Op) && *inst.Op != "id" {
+		return lattice.Constant{State: lattice.Bottom}
+	}
+
+	var args []ops.Value
+	for _, arg := range inst.Args {
+		c := env.Get(arg)
+		if c.State != lattice.Known {
+			// ⊤ stays ⊤ until the argument is better known
+			return c
+		}
+		args = append(args, c.Value)
+	}
+	if v, ok := ops.Fold(*inst.Op, args); ok {
+		return lattice.Constant{State: lattice.Known, Value: v}
+	}
+	return lattice.Constant{State: lattice.Bottom}
+}
+
+func constants(function models.Function) (namesInOrder []string, nameToProgramPoint map[string]*df.ProgramPoint[lattice.ConstantLattice]) {
+	namesInOrder, nameToBlock, cfg := blocks(function)
+
+	nameToProgramPoint = dfutils.MakeNameToProgramPoint(nameToBlock, lattice.NewConstantLattice)
+
+	// Arguments could be anything
+	entry := lattice.NewConstantLattice()
+	for _, arg := range function.Args {
+		entry.Vars[arg.Name] = lattice.Constant{State: lattice.Bottom}
+	}
+	nameToProgramPoint[namesInOrder[0]].In = entry
+
+	solve(nameToProgramPoint, cfg, namesInOrder, df.Forward, folded)
+
+	return namesInOrder, nameToProgramPoint
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"sort"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	dfutils "aaronstgeorge.com/self-guided-cs-1620/pkg/df/utils"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ops"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/text"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// expression - the canonical text of the computation inst performs, the same
+// operation on the same arguments gives the same text. ok is false when inst
+// isn't a pure computation. Constants are left out, there is nothing to gain
+// from knowing one is available.
+func expression(inst models.Instruction) (string, bool) {
+	if inst.Op == nil || inst.Dest == nil || !ops.Pure.Contains(*inst.Op) {
+		return "", false
+	}
+	key := models.Instruction{Op: inst.Op, Args: inst.Args}
+	if ops.Commutative.Contains(*inst.Op) {
+		args := make([]string, len(inst.Args))
+		copy(args, inst.Args)
+		sort.Strings(args)
+		key.Args = args
+	}
+	return text.Instruction(key), true
+}
+
+// expressions is every expression computed by a function, with an index from
+// variables to the expressions that use them. Redefining a variable kills
+// those expressions.
+type expressions struct {
+	all   utils.Set
+	using map[string]utils.Set
+}
+
+func collectExpressions(nameToBlock map[string][]models.Instruction) expressions {
+	e := expressions{all: make(utils.Set), using: make(map[string]utils.Set)}
+	for _, block := range nameToBlock {
+		for _, inst := range block {
+			expr, ok := expression(inst)
+			if !ok {
+				continue
+			}
+			e.all.Add(expr)
+			for _, arg := range inst.Args {
+				if e.using[arg] == nil {
+					e.using[arg] = make(utils.Set)
+				}
+				e.using[arg].Add(expr)
+			}
+		}
+	}
+	return e
+}
+
+func (e expressions) kill(set utils.Set, v string) {
+	for expr := range e.using[v] {
+		set.Remove(expr)
+	}
+}
+
+// available - expressions that have been computed on every path to a point
+// and whose arguments haven't been redefined since.
+func available(function models.Function) ([]string, map[string]*df.ProgramPoint[lattice.IntersetMeetSetLattice], func(string, []models.Instruction, lattice.IntersetMeetSetLattice) lattice.IntersetMeetSetLattice) {
+	namesInOrder, nameToBlock, cfg := blocks(function)
+	e := collectExpressions(nameToBlock)
+
+	transfer := func(_ string, instructions []models.Instruction, in lattice.IntersetMeetSetLattice) lattice.IntersetMeetSetLattice {
+		out := utils.Union(in.Set, nil)
+		for _, inst := range instructions {
+			if expr, ok := expression(inst); ok {
+				out.Add(expr)
+			}
+			// After adding, a = add a b doesn't make add a b
+			// available
+			if inst.Dest != nil {
+				e.kill(out, *inst.Dest)
+			}
+		}
+		return lattice.IntersetMeetSetLattice{Set: out}
+	}
+
+	// Intersection only shrinks sets so everything starts out with every
+	// expression, except the entry where nothing has been computed.
+	nameToProgramPoint := dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.IntersetMeetSetLattice {
+		return lattice.IntersetMeetSetLattice{Set: utils.Union(e.all, nil)}
+	})
+	nameToProgramPoint[namesInOrder[0]].In = lattice.IntersetMeetSetLattice{Set: make(utils.Set)}
+
+	solve(nameToProgramPoint, cfg, namesInOrder, df.Forward, transfer)
+
+	return namesInOrder, nameToProgramPoint, transfer
+}
+
+// busy - expressions that will be computed on every path from a point before
+// any of their arguments are redefined.
+func busy(function models.Function) ([]string, map[string]*df.ProgramPoint[lattice.IntersetMeetSetLattice], func(string, []models.Instruction, lattice.IntersetMeetSetLattice) lattice.IntersetMeetSetLattice) {
+	namesInOrder, nameToBlock, cfg := blocks(function)
+	e := collectExpressions(nameToBlock)
+
+	transfer := func(_ string, instructions []models.Instruction, out lattice.IntersetMeetSetLattice) lattice.IntersetMeetSetLattice {
+		in := utils.Union(out.Set, nil)
+		for i := len(instructions) - 1; i >= 0; i-- {
+			inst := instructions[i]
+			// Going backwards the arguments are read before the
+			// destination is written, so kill first.
+			if inst.Dest != nil {
+				e.kill(in, *inst.Dest)
+			}
+			if expr, ok := expression(inst); ok {
+				in.Add(expr)
+			}
+		}
+		return lattice.IntersetMeetSetLattice{Set: in}
+	}
+
+	// Same as available but backwards, nothing is computed after leaving
+	// the function.
+	nameToProgramPoint := dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.IntersetMeetSetLattice {
+		return lattice.IntersetMeetSetLattice{Set: utils.Union(e.all, nil)}
+	})
+	var workList []string
+	for i := len(namesInOrder) - 1; i >= 0; i-- {
+		name := namesInOrder[i]
+		if len(utils.Successors(cfg, name)) == 0 {
+			nameToProgramPoint[name].Out = lattice.IntersetMeetSetLattice{Set: make(utils.Set)}
+		}
+		workList = append(workList, name)
+	}
+
+	solve(nameToProgramPoint, cfg, workList, df.Reverse, transfer)
+
+	return namesInOrder, nameToProgramPoint, transfer
+}
@@ -1,103 +1,213 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/chains"
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/intervals"
 
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/liveness"
 
 	dfutils "aaronstgeorge.com/self-guided-cs-1620/pkg/df/utils"
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/text"
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
 )
 
-func defed(_ string, instructions []models.Instruction, in lattice.UnionMeetSetLattice) lattice.UnionMeetSetLattice {
-	out := make(utils.Set)
-	for _, inst := range instructions {
-		if inst.Dest != nil {
-			out.Add(*inst.Dest)
-		}
+var maxIterations = flag.Int("max", 0, "give up after applying a transfer function this many times, 0 for no limit")
+
+var printStats = flag.Bool("stats", false, "print how many times transfer functions were applied")
+
+// stats of every analysis run so far
+var stats df.Stats
+
+// fail reports err and exits, there's nothing to print for an analysis that
+// couldn't be done
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	os.Exit(1)
+}
+
+// blocks - the basic blocks of function and its control flow graph
+func blocks(function models.Function) ([]string, map[string][]models.Instruction, utils.Digraph) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		fail(fmt.Errorf("@%s: %w", function.Name, err))
 	}
-	return lattice.UnionMeetSetLattice{Set: utils.Union(in.Set, out)}
+	return namesInOrder, nameToBlock, cfg
 }
 
-func used(_ string, instructions []models.Instruction, in lattice.UnionMeetSetLattice) lattice.UnionMeetSetLattice {
-	used := make(utils.Set)
-	defined := make(utils.Set)
+// solve is df.Solve with the limit from the command line. There's nothing to
+// print if the analysis doesn't finish.
+func solve[T lattice.Lattice[T]](nameToProgramPoint map[string]*df.ProgramPoint[T],
+	cfg utils.Digraph,
+	workList []string,
+	direction df.Direction,
+	transfer func(string, []models.Instruction, T) T) {
+
+	s, err := df.Solve(nameToProgramPoint, cfg, workList, direction, transfer, df.Options[T]{MaxIterations: *maxIterations})
+	if err != nil {
+		fail(err)
+	}
+	stats.TransferCalls += s.TransferCalls
+}
+
+func defed(_ string, instructions []models.Instruction, in lattice.UnionMeetBitSetLattice) lattice.UnionMeetBitSetLattice {
+	out := in.Copy()
 	for _, inst := range instructions {
-		for _, arg := range inst.Args {
-			if !defined.Contains(arg) {
-				used.Add(arg)
-			}
-		}
 		if inst.Dest != nil {
-			defined.Add(*inst.Dest)
+			out.Add(*inst.Dest)
 		}
 	}
-	return lattice.UnionMeetSetLattice{Set: utils.Union(utils.Sub(in.Set, defined), used)}
+	return lattice.UnionMeetBitSetLattice{BitSet: out}
 }
 
-func defined(prog models.Program) (namesInOrder []string, nameToProgramPoint map[string]*df.ProgramPoint[lattice.UnionMeetSetLattice]) {
-	// the [0] is definitely not a reasonable thing to do in a production circumstance
-	namesInOrder, nameToBlock := utils.BasicBlocks(prog.Functions[0])
-	cfg := utils.CFG(namesInOrder, nameToBlock)
+func defined(function models.Function) (namesInOrder []string, nameToProgramPoint map[string]*df.ProgramPoint[lattice.UnionMeetBitSetLattice]) {
+	namesInOrder, nameToBlock, cfg := blocks(function)
+	u := liveness.Variables(function)
 
-	nameToProgramPoint = dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.UnionMeetSetLattice {
-		return lattice.UnionMeetSetLattice{Set: make(utils.Set)}
+	nameToProgramPoint = dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.UnionMeetBitSetLattice {
+		return lattice.UnionMeetBitSetLattice{BitSet: u.Empty()}
 	})
 
-	workList := []string{namesInOrder[0]}
-	df.DF(nameToProgramPoint, cfg, workList, df.Forward, defed)
+	solve(nameToProgramPoint, cfg, namesInOrder, df.Forward, defed)
 
 	return namesInOrder, nameToProgramPoint
 }
 
-func live(prog models.Program) (namesInOrder []string, nameToProgramPoint map[string]*df.ProgramPoint[lattice.UnionMeetSetLattice]) {
-	// the [0] is definitely not a reasonable thing to do in a production circumstance
-	namesInOrder, nameToBlock := utils.BasicBlocks(prog.Functions[0])
-	cfg := utils.CFG(namesInOrder, nameToBlock)
+func live(function models.Function) (namesInOrder []string, nameToProgramPoint map[string]*df.ProgramPoint[lattice.UnionMeetBitSetLattice]) {
+	namesInOrder, nameToBlock, cfg := blocks(function)
+	u := liveness.Variables(function)
 
-	nameToProgramPoint = dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.UnionMeetSetLattice {
-		return lattice.UnionMeetSetLattice{Set: make(utils.Set)}
+	nameToProgramPoint = dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.UnionMeetBitSetLattice {
+		return lattice.UnionMeetBitSetLattice{BitSet: u.Empty()}
 	})
 
-	workList := []string{namesInOrder[len(namesInOrder)-1]}
-	df.DF(nameToProgramPoint, cfg, workList, df.Reverse, used)
+	solve(nameToProgramPoint, cfg, namesInOrder, df.Reverse, liveness.Transfer)
 
 	return namesInOrder, nameToProgramPoint
 }
 
 func output[T lattice.Lattice[T]](namesInOrder []string, nameToProgramPoint map[string]*df.ProgramPoint[T]) {
 	for _, name := range namesInOrder {
-		fmt.Printf("%s:\n", name)
-		fmt.Printf("  in:  %s\n", nameToProgramPoint[name].In)
-		fmt.Printf("  out: %s\n", nameToProgramPoint[name].Out)
+		fmt.Printf("  %s:\n", name)
+		fmt.Printf("    in:  %s\n", nameToProgramPoint[name].In)
+		fmt.Printf("    out: %s\n", nameToProgramPoint[name].Out)
+	}
+}
+
+// outputInstructions is like output but the facts before and after every
+// instruction are printed beside it.
+func outputInstructions[T lattice.Lattice[T]](namesInOrder []string,
+	nameToProgramPoint map[string]*df.ProgramPoint[T],
+	direction df.Direction,
+	transfer func(string, []models.Instruction, T) T) {
+
+	nameToPoints := df.InstructionPoints(nameToProgramPoint, direction, transfer)
+	for _, name := range namesInOrder {
+		fmt.Printf("  %s:\n", name)
+		fmt.Printf("    in:  %s\n", nameToProgramPoint[name].In)
+		for _, point := range nameToPoints[name] {
+			inst := text.Instruction(point.Instruction)
+			if point.Instruction.Op != nil {
+				inst += ";"
+			}
+			fmt.Printf("      %-30s in: %s | out: %s\n", inst, point.In, point.Out)
+		}
+		fmt.Printf("    out: %s\n", nameToProgramPoint[name].Out)
 	}
 }
 
+// report prints the solution, with the per instruction facts if asked for
+func report[T lattice.Lattice[T]](namesInOrder []string,
+	nameToProgramPoint map[string]*df.ProgramPoint[T],
+	direction df.Direction,
+	transfer func(string, []models.Instruction, T) T,
+	perInstruction bool) {
+
+	if perInstruction {
+		outputInstructions(namesInOrder, nameToProgramPoint, direction, transfer)
+	} else {
+		output(namesInOrder, nameToProgramPoint)
+	}
+}
+
+// analyses - every analysis run on one function, printing its solution
+var analyses = map[string]func(function models.Function, perInstruction bool){
+	"defined": func(function models.Function, perInstruction bool) {
+		namesInOrder, nameToProgramPoint := defined(function)
+		report(namesInOrder, nameToProgramPoint, df.Forward, defed, perInstruction)
+	},
+	"live": func(function models.Function, perInstruction bool) {
+		namesInOrder, nameToProgramPoint := live(function)
+		report(namesInOrder, nameToProgramPoint, df.Reverse, liveness.Transfer, perInstruction)
+	},
+	"reaching": func(function models.Function, perInstruction bool) {
+		r, err := chains.ReachingDefinitions(function)
+		if err != nil {
+			fail(fmt.Errorf("@%s: %w", function.Name, err))
+		}
+		stats.TransferCalls += r.Stats.TransferCalls
+		report(r.NamesInOrder, r.NameToProgramPoint, df.Forward, r.Transfer, perInstruction)
+	},
+	"const": func(function models.Function, perInstruction bool) {
+		namesInOrder, nameToProgramPoint := constants(function)
+		report(namesInOrder, nameToProgramPoint, df.Forward, folded, perInstruction)
+	},
+	"intervals": func(function models.Function, perInstruction bool) {
+		r, err := intervals.Analyze(function, *maxIterations)
+		if err != nil {
+			fail(err)
+		}
+		stats.TransferCalls += r.Stats.TransferCalls
+		report(r.NamesInOrder, r.NameToProgramPoint, df.Forward, r.Transfer, perInstruction)
+	},
+	"available": func(function models.Function, perInstruction bool) {
+		namesInOrder, nameToProgramPoint, transfer := available(function)
+		report(namesInOrder, nameToProgramPoint, df.Forward, transfer, perInstruction)
+	},
+	"busy": func(function models.Function, perInstruction bool) {
+		namesInOrder, nameToProgramPoint, transfer := busy(function)
+		report(namesInOrder, nameToProgramPoint, df.Reverse, transfer, perInstruction)
+	},
+}
+
 func main() {
-	args := os.Args[1:]
+	perInstruction := flag.Bool("i", false, "print the facts before and after every instruction")
+	flag.Parse()
+	args := flag.Args()
 
 	if len(args) != 1 {
-		println("usage: df analysis")
+		println("usage: df [-i] [-max n] [-stats] analysis")
 		os.Exit(1)
 	}
 
-	prog := utils.ReadProgram()
-
-	var namesInOrder []string
-	var nameToProgramPoint map[string]*df.ProgramPoint[lattice.UnionMeetSetLattice]
-	switch args[0] {
-	case "defined":
-		namesInOrder, nameToProgramPoint = defined(prog)
-	case "live":
-		namesInOrder, nameToProgramPoint = live(prog)
-	default:
+	analysis, ok := analyses[args[0]]
+	if !ok {
 		println("unknown analysis")
 		os.Exit(1)
 	}
 
-	output(namesInOrder, nameToProgramPoint)
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fail(err)
+	}
+
+	for _, function := range prog.Functions {
+		fmt.Printf("@%s:\n", function.Name)
+		// There's nothing to analyze and no entry block to start from
+		if len(function.Instrs) == 0 {
+			continue
+		}
+		analysis(function, *perInstruction)
+	}
+
+	if *printStats {
+		fmt.Printf("transfer calls: %d\n", stats.TransferCalls)
+	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/dominators"
@@ -8,6 +9,45 @@ import (
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
 )
 
+// Commands that print a set for every block. The post dominator commands
+// include the virtual exit.
+var sets = map[string]func(namesInOrder []string, cfg utils.Digraph) ([]string, map[string]utils.Set, error){
+	"dom": func(namesInOrder []string, cfg utils.Digraph) ([]string, map[string]utils.Set, error) {
+		return namesInOrder, dominators.Dominators(namesInOrder, cfg), nil
+	},
+	"front": func(namesInOrder []string, cfg utils.Digraph) ([]string, map[string]utils.Set, error) {
+		domTree := dominators.Tree(namesInOrder, cfg)
+		front, err := dominators.Front(namesInOrder, cfg, domTree)
+		return namesInOrder, front, err
+	},
+	"pdom": func(namesInOrder []string, cfg utils.Digraph) ([]string, map[string]utils.Set, error) {
+		return withExit(namesInOrder), dominators.PostDominators(namesInOrder, cfg), nil
+	},
+	"pfront": func(namesInOrder []string, cfg utils.Digraph) ([]string, map[string]utils.Set, error) {
+		postTree := dominators.PostTree(namesInOrder, cfg)
+		front, err := dominators.PostFront(namesInOrder, cfg, postTree)
+		return withExit(namesInOrder), front, err
+	},
+}
+
+// Commands that draw a graph over the blocks
+var graphs = map[string]func(namesInOrder []string, cfg utils.Digraph) ([]string, utils.Digraph, error){
+	"tree": func(namesInOrder []string, cfg utils.Digraph) ([]string, utils.Digraph, error) {
+		return namesInOrder, dominators.Tree(namesInOrder, cfg), nil
+	},
+	"ptree": func(namesInOrder []string, cfg utils.Digraph) ([]string, utils.Digraph, error) {
+		return withExit(namesInOrder), dominators.PostTree(namesInOrder, cfg), nil
+	},
+	"cdg": func(namesInOrder []string, cfg utils.Digraph) ([]string, utils.Digraph, error) {
+		cdg, err := dominators.ControlDependence(namesInOrder, cfg)
+		return namesInOrder, cdg, err
+	},
+}
+
+func withExit(namesInOrder []string) []string {
+	return append(append([]string(nil), namesInOrder...), dominators.Exit)
+}
+
 func main() {
 	args := os.Args[1:]
 
@@ -16,26 +56,46 @@ func main() {
 		os.Exit(1)
 	}
 
-	prog := utils.ReadProgram()
-
-	//// the [0] is definitely not a reasonable thing to do in a production circumstance
-	namesInOrder, nameToBlock := utils.BasicBlocks(prog.Functions[0])
-	cfg := utils.CFG(namesInOrder, nameToBlock)
-
-	switch args[0] {
-	case "dom":
-		nameToDominators := dominators.Dominators(namesInOrder, nameToBlock, cfg)
-		utils.OutputBlockNameToSet(namesInOrder, nameToDominators)
-	case "tree":
-		nameToDominators := dominators.Dominators(namesInOrder, nameToBlock, cfg)
-		utils.OutputDot(namesInOrder, dominators.Tree(namesInOrder, cfg, nameToDominators))
-	case "front":
-		nameToDominators := dominators.Dominators(namesInOrder, nameToBlock, cfg)
-		domTree := dominators.Tree(namesInOrder, cfg, nameToDominators)
-		front := dominators.Front(namesInOrder, cfg, domTree)
-		utils.OutputBlockNameToSet(namesInOrder, front)
-	default:
+	set, isSet := sets[args[0]]
+	graph, isGraph := graphs[args[0]]
+	if !isSet && !isGraph {
 		println("unknown command")
 		os.Exit(1)
 	}
+
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	var functionGraphs []utils.FunctionGraph
+	for _, function := range prog.Functions {
+		namesInOrder, nameToBlock := utils.BasicBlocks(function)
+		cfg, err := utils.CFG(namesInOrder, nameToBlock)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+			os.Exit(1)
+		}
+
+		if isSet {
+			namesInOrder, nameToSet, err := set(namesInOrder, cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("@%s:\n", function.Name)
+			utils.OutputBlockNameToSet(namesInOrder, nameToSet)
+		} else {
+			namesInOrder, g, err := graph(namesInOrder, cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+				os.Exit(1)
+			}
+			functionGraphs = append(functionGraphs, utils.FunctionGraph{Name: function.Name, NamesInOrder: namesInOrder, Graph: g})
+		}
+	}
+	if isGraph {
+		utils.OutputDot(functionGraphs)
+	}
 }
@@ -0,0 +1,32 @@
+// Global value numbering
+//
+// The input program must be in SSA form, run it through to-ssa first.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/gvn"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func main() {
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	for i, function := range prog.Functions {
+		if prog.Functions[i], err = gvn.GVN(function); err != nil {
+			fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := utils.PrintProgram(prog); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,9 +1,19 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
 )
 
 func main() {
-	utils.PrintProgram(utils.ReadProgram())
+	prog, err := utils.ReadProgram()
+	if err == nil {
+		err = utils.PrintProgram(prog)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
 }
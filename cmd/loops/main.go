@@ -0,0 +1,128 @@
+// Natural loop detection
+//
+// Usage: loops forest|dot
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/dominators"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/loops"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func forest(function models.Function) ([]string, utils.Digraph, []*loops.Loop) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	if len(namesInOrder) == 0 {
+		return nil, nil, nil
+	}
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+		os.Exit(1)
+	}
+	nameToDominators := dominators.Dominators(namesInOrder, cfg)
+	return namesInOrder, cfg, loops.Forest(namesInOrder, cfg, nameToDominators)
+}
+
+func outputForest(function models.Function) {
+	fmt.Printf("@%s:\n", function.Name)
+	_, _, f := forest(function)
+	loops.Walk(f, func(l *loops.Loop) {
+		indent := strings.Repeat("  ", l.Depth())
+		fmt.Printf("%sloop %s:\n", indent, l.Header)
+		fmt.Printf("%s  body: %s\n", indent, l.Body)
+		fmt.Printf("%s  latches: %s\n", indent, strings.Join(l.Latches, ", "))
+		var exits []string
+		for _, e := range l.Exits {
+			exits = append(exits, fmt.Sprintf("%s -> %s", e.From, e.To))
+		}
+		fmt.Printf("%s  exits: %s\n", indent, strings.Join(exits, ", "))
+		if l.Preheader != "" {
+			fmt.Printf("%s  preheader: %s\n", indent, l.Preheader)
+		}
+	})
+}
+
+// outputDot draws the control flow graph of every function with each loop
+// as a cluster, nested loops are nested clusters.
+func outputDot(prog models.Program) {
+	fmt.Println("digraph G {")
+	for _, function := range prog.Functions {
+		namesInOrder, cfg, f := forest(function)
+		node := func(name string) string {
+			return fmt.Sprintf("\"%s.%s\"", function.Name, name)
+		}
+
+		// Only the innermost loop containing a block should list it
+		innermost := make(map[string]*loops.Loop)
+		loops.Walk(f, func(l *loops.Loop) {
+			for name := range l.Body {
+				innermost[name] = l
+			}
+		})
+
+		var cluster func(l *loops.Loop, indent string)
+		cluster = func(l *loops.Loop, indent string) {
+			fmt.Printf("%ssubgraph \"cluster_%s.%s\" {\n", indent, function.Name, l.Header)
+			fmt.Printf("%s  label = \"loop %s\";\n", indent, l.Header)
+			for _, name := range namesInOrder {
+				if innermost[name] == l {
+					fmt.Printf("%s  %s [label = \"%s\"];\n", indent, node(name), name)
+				}
+			}
+			for _, child := range l.Children {
+				cluster(child, indent+"  ")
+			}
+			fmt.Printf("%s}\n", indent)
+		}
+
+		fmt.Printf("  subgraph \"cluster_%s\" {\n", function.Name)
+		fmt.Printf("    label = \"@%s\";\n", function.Name)
+		for _, name := range namesInOrder {
+			if _, ok := innermost[name]; !ok {
+				fmt.Printf("    %s [label = \"%s\"];\n", node(name), name)
+			}
+		}
+		for _, l := range f {
+			cluster(l, "    ")
+		}
+		for _, name := range namesInOrder {
+			for _, succ := range utils.Successors(cfg, name) {
+				fmt.Printf("    %s -> %s;\n", node(name), node(succ))
+			}
+		}
+		fmt.Println("  }")
+	}
+	fmt.Println("}")
+}
+
+func main() {
+	args := os.Args[1:]
+
+	if len(args) != 1 {
+		println("usage: loops forest|dot")
+		os.Exit(1)
+	}
+
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "forest":
+		for _, function := range prog.Functions {
+			outputForest(function)
+		}
+	case "dot":
+		outputDot(prog)
+	default:
+		println("unknown command")
+		os.Exit(1)
+	}
+}
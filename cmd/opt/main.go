@@ -0,0 +1,48 @@
+// Runs a pipeline of passes over a program, reading and printing it once
+//
+// Usage: opt -passes=to-ssa,sccp,gvn,adce,from-ssa [-stats]
+//
+// fixpoint(a,b) in the pipeline runs a and b until neither changes anything.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/pass"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func main() {
+	pipeline := flag.String("passes", "", "comma separated passes to run, one of "+strings.Join(pass.Names(), ", "))
+	printStats := flag.Bool("stats", false, "print how many times each analysis was computed and reused to stderr")
+	flag.Parse()
+
+	passes, err := pass.Parse(*pipeline, pass.Lookup)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	m := pass.NewManager()
+	if prog, err = m.Run(prog, passes); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+	if *printStats {
+		fmt.Fprintln(os.Stderr, m.Stats)
+	}
+
+	if err := utils.PrintProgram(prog); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,58 @@
+// Reports everything that keeps a program from being in SSA form, the exit
+// code is 1 if there is anything to report. With -assert the program is
+// passed through when it's in SSA form so ssa-check can sit between
+// transforms in a pipeline.
+//
+// Usage: ssa-check [-assert]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ssa"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func main() {
+	assert := flag.Bool("assert", false, "print the program if it's in SSA form, the first violation if it isn't")
+	flag.Parse()
+
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *assert {
+		for _, function := range prog.Functions {
+			if err := ssa.Check(function); err != nil {
+				fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+				os.Exit(1)
+			}
+		}
+		if err := utils.PrintProgram(prog); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	failed := false
+	for _, function := range prog.Functions {
+		violations, err := ssa.Verify(function)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("@%s:\n", function.Name)
+		for _, v := range violations {
+			fmt.Printf("  %s\n", v)
+		}
+		failed = failed || len(violations) != 0
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
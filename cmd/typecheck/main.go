@@ -0,0 +1,38 @@
+// Reports instructions given arguments of the wrong type or whose destination
+// is declared with the wrong type, the exit code is 1 if there are any
+//
+// Usage: typecheck
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/typecheck"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func main() {
+	prog, err := utils.ReadProgram()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, function := range prog.Functions {
+		errors, err := typecheck.Check(prog, function)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: @%s: %s\n", function.Name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("@%s:\n", function.Name)
+		for _, e := range errors {
+			fmt.Printf("  %s\n", e)
+		}
+		failed = failed || len(errors) != 0
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,180 @@
+// Package adce implements aggressive dead code elimination. Instead of
+// removing what is obviously unused, like cmd/tdce, everything is assumed
+// dead until it's needed by an instruction with a side effect. That also
+// removes computations that only feed each other and branches that don't
+// decide whether anything useful happens.
+package adce
+
+import (
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/chains"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/dominators"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// Operations that are always needed, the roots of the marking
+var effects = utils.NewSet("print", "store", "ret", "call", "free")
+
+type marker struct {
+	nameToBlock map[string][]models.Instruction
+	useDef      map[chains.Use][]chains.Def
+	// nameToFront is the post dominance frontier, the blocks a block is
+	// control dependent on
+	nameToFront map[string]utils.Set
+	marked      map[utils.Site]bool
+	useful      utils.Set
+	workList    []utils.Site
+}
+
+func (m *marker) mark(s utils.Site) {
+	if m.marked[s] {
+		return
+	}
+	m.marked[s] = true
+	m.workList = append(m.workList, s)
+}
+
+// markBlock marks the branches that decide whether block runs
+func (m *marker) markBlock(block string) {
+	if m.useful.Contains(block) {
+		return
+	}
+	m.useful.Add(block)
+	for branch := range m.nameToFront[block] {
+		m.mark(utils.Site{Block: branch, Index: len(m.nameToBlock[branch]) - 1})
+	}
+}
+
+func (m *marker) run() {
+	for len(m.workList) != 0 {
+		s := m.workList[len(m.workList)-1]
+		m.workList = m.workList[:len(m.workList)-1]
+
+		m.markBlock(s.Block)
+		inst := m.nameToBlock[s.Block][s.Index]
+		for _, arg := range inst.Args {
+			for _, d := range m.useDef[chains.Use{Block: s.Block, Index: s.Index, Var: arg}] {
+				if d.Index != chains.ArgIndex {
+					m.mark(utils.Site{Block: d.Block, Index: d.Index})
+				}
+			}
+		}
+		// Which value a phi node takes depends on the block control
+		// came from
+		if *inst.Op == "phi" {
+			for _, label := range inst.Labels {
+				m.markBlock(label)
+			}
+		}
+	}
+}
+
+// ADCE removes every instruction in function that doesn't contribute to a
+// side effect. A branch that isn't needed is replaced by a jump to the
+// nearest post dominator that does something. Like most versions of ADCE a
+// loop that does nothing is removed, but a branch that can lead to a block
+// that never returns is kept so that an infinite loop stays infinite.
+func ADCE(function models.Function) (models.Function, error) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	if len(namesInOrder) == 0 {
+		return function, nil
+	}
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		return function, err
+	}
+	return Sweep(function, namesInOrder, nameToBlock, cfg)
+}
+
+// Sweep is ADCE for when the blocks of function and its control flow graph
+// are already known.
+func Sweep(function models.Function, namesInOrder []string, nameToBlock map[string][]models.Instruction, cfg utils.Digraph) (models.Function, error) {
+	if len(namesInOrder) == 0 {
+		return function, nil
+	}
+	c, err := chains.Build(function)
+	if err != nil {
+		return function, err
+	}
+	nameToFront, err := dominators.PostFront(namesInOrder, cfg, dominators.PostTree(namesInOrder, cfg))
+	if err != nil {
+		return function, err
+	}
+	ipdoms := dominators.ImmediateDominators(dominators.Reverse(namesInOrder, cfg))
+
+	// Whether a return can be reached from name
+	returns := func(name string) bool {
+		_, ok := ipdoms[name]
+		return ok
+	}
+
+	m := &marker{
+		nameToBlock: nameToBlock,
+		useDef:      c.UseDef,
+		nameToFront: nameToFront,
+		marked:      make(map[utils.Site]bool),
+		useful:      utils.NewSet(),
+	}
+	for _, name := range namesInOrder {
+		block := nameToBlock[name]
+		for i, inst := range block {
+			if inst.Op != nil && effects.Contains(*inst.Op) {
+				m.mark(utils.Site{Block: name, Index: i})
+			}
+		}
+		if len(block) != 0 && block[len(block)-1].Op != nil && *block[len(block)-1].Op == "br" {
+			for _, succ := range utils.Successors(cfg, name) {
+				if !returns(succ) || !returns(name) {
+					m.mark(utils.Site{Block: name, Index: len(block) - 1})
+				}
+			}
+		}
+	}
+	m.run()
+
+	// The nearest post dominator of name with something to do
+	usefulPostDominator := func(name string) string {
+		for {
+			name = ipdoms[name]
+			if name == dominators.Exit || m.useful.Contains(name) {
+				return name
+			}
+		}
+	}
+
+	labeled := utils.NewSet()
+	var out [][]models.Instruction
+	for _, name := range namesInOrder {
+		var block []models.Instruction
+		for i, inst := range nameToBlock[name] {
+			switch {
+			case inst.Op == nil || *inst.Op == "jmp" || m.marked[utils.Site{Block: name, Index: i}]:
+				block = append(block, inst)
+			case *inst.Op == "br":
+				target := usefulPostDominator(name)
+				if target == dominators.Exit {
+					ret := "ret"
+					block = append(block, models.Instruction{Op: &ret})
+				} else {
+					jmp := "jmp"
+					block = append(block, models.Instruction{Op: &jmp, Labels: []string{target}})
+					labeled.Add(target)
+				}
+			}
+		}
+		out = append(out, block)
+	}
+
+	// A block that is now jumped to needs a label
+	var instrs []models.Instruction
+	for i, name := range namesInOrder {
+		block := out[i]
+		if labeled.Contains(name) && (len(block) == 0 || block[0].Label == nil) {
+			label := name
+			block = append([]models.Instruction{{Label: &label}}, block...)
+		}
+		instrs = append(instrs, block...)
+	}
+	function.Instrs = instrs
+	return function, nil
+}
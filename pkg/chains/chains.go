@@ -0,0 +1,95 @@
+package chains
+
+import (
+	"sort"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// Use is a use of Var as an argument of the instruction at Index in Block.
+type Use struct {
+	Block string
+	Index int
+	Var   string
+}
+
+// Chains connects every use of a variable to the definitions that can reach
+// it and every definition to the uses it can reach.
+type Chains struct {
+	// UseDef has an entry for every use, a use with no definitions is of a
+	// variable that may be undefined on every path.
+	UseDef map[Use][]Def
+	// DefUse has an entry for every definition, including the dead ones.
+	DefUse map[Def][]Use
+	// Uses and Defs are in program order, handy for printing.
+	Uses []Use
+	Defs []Def
+}
+
+// Build computes the use-def and def-use chains of function from its reaching
+// definitions.
+func Build(function models.Function) (Chains, error) {
+	r, err := ReachingDefinitions(function)
+	if err != nil {
+		return Chains{}, err
+	}
+	c := Chains{
+		UseDef: make(map[Use][]Def),
+		DefUse: make(map[Def][]Use),
+	}
+
+	for _, arg := range function.Args {
+		if len(r.NamesInOrder) != 0 {
+			d := Def{Block: r.NamesInOrder[0], Index: ArgIndex, Var: arg.Name}
+			c.Defs = append(c.Defs, d)
+			c.DefUse[d] = nil
+		}
+	}
+
+	for _, name := range r.NamesInOrder {
+		reaching := utils.Union(r.NameToProgramPoint[name].In.Set, nil)
+		for i, inst := range r.nameToBlock[name] {
+			// Each variable is looked up once even if it's used
+			// more than once by the same instruction
+			seen := utils.NewSet()
+			for _, arg := range inst.Args {
+				if seen.Contains(arg) {
+					continue
+				}
+				seen.Add(arg)
+
+				u := Use{Block: name, Index: i, Var: arg}
+				c.Uses = append(c.Uses, u)
+				c.UseDef[u] = r.defsOf(reaching, arg)
+				for _, d := range c.UseDef[u] {
+					c.DefUse[d] = append(c.DefUse[d], u)
+				}
+			}
+
+			if inst.Dest != nil {
+				d := Def{Block: name, Index: i, Var: *inst.Dest}
+				c.Defs = append(c.Defs, d)
+				if _, ok := c.DefUse[d]; !ok {
+					c.DefUse[d] = nil
+				}
+			}
+			reaching = r.Transfer(name, r.nameToBlock[name][i:i+1], lattice.UnionMeetSetLattice{Set: reaching}).Set
+		}
+	}
+	return c, nil
+}
+
+// defsOf - the definitions of v in the set of reaching definitions, in a
+// stable order.
+func (r *Reaching) defsOf(reaching utils.Set, v string) []Def {
+	var defs []Def
+	for _, key := range r.varToKeys[v] {
+		if reaching.Contains(key) {
+			defs = append(defs, r.keyToDef[key])
+		}
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].String() < defs[j].String() })
+	return defs
+}
@@ -0,0 +1,144 @@
+// Package chains computes reaching definitions and from them use-def and
+// def-use chains.
+package chains
+
+import (
+	"fmt"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	dfutils "aaronstgeorge.com/self-guided-cs-1620/pkg/df/utils"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// ArgIndex is the index given to the definitions of function arguments. They
+// are defined before the first instruction of the entry block.
+const ArgIndex = -1
+
+// Def is a definition of Var by the instruction at Index in Block.
+type Def struct {
+	Block string
+	Index int
+	Var   string
+}
+
+func (d Def) String() string {
+	if d.Index == ArgIndex {
+		return fmt.Sprintf("%s@arg", d.Var)
+	}
+	return fmt.Sprintf("%s@%s:%d", d.Var, d.Block, d.Index)
+}
+
+// Reaching is the result of the reaching definitions analysis. The sets in
+// the program points contain the String of each Def, use Def to get them
+// back.
+type Reaching struct {
+	NamesInOrder       []string
+	NameToProgramPoint map[string]*df.ProgramPoint[lattice.UnionMeetSetLattice]
+	Stats              df.Stats
+
+	nameToBlock map[string][]models.Instruction
+	keyToDef    map[string]Def
+	siteToKey   map[utils.Site]string
+	varToKeys   map[string][]string
+}
+
+// ReachingDefinitions finds, for the start and end of every block in
+// function, which definitions may have assigned the current value of a
+// variable.
+func ReachingDefinitions(function models.Function) (*Reaching, error) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	r := &Reaching{
+		NamesInOrder: namesInOrder,
+		nameToBlock:  nameToBlock,
+		keyToDef:     make(map[string]Def),
+		siteToKey:    make(map[utils.Site]string),
+		varToKeys:    make(map[string][]string),
+	}
+	if len(namesInOrder) == 0 {
+		r.NameToProgramPoint = make(map[string]*df.ProgramPoint[lattice.UnionMeetSetLattice])
+		return r, nil
+	}
+
+	add := func(d Def) string {
+		key := d.String()
+		r.keyToDef[key] = d
+		r.varToKeys[d.Var] = append(r.varToKeys[d.Var], key)
+		return key
+	}
+	args := make(utils.Set)
+	for _, arg := range function.Args {
+		args.Add(add(Def{Block: namesInOrder[0], Index: ArgIndex, Var: arg.Name}))
+	}
+	for _, name := range namesInOrder {
+		for i, inst := range nameToBlock[name] {
+			if inst.Dest != nil {
+				r.siteToKey[utils.Site{Block: name, Index: i}] = add(Def{Block: name, Index: i, Var: *inst.Dest})
+			}
+		}
+	}
+
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		return nil, err
+	}
+	r.NameToProgramPoint = dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.UnionMeetSetLattice {
+		return lattice.UnionMeetSetLattice{Set: make(utils.Set)}
+	})
+	// Boundary condition, the arguments are defined on the way in
+	r.NameToProgramPoint[namesInOrder[0]].In = lattice.UnionMeetSetLattice{Set: args}
+
+	r.Stats, err = df.DF(r.NameToProgramPoint, cfg, namesInOrder, df.Forward, r.Transfer)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Transfer is the transfer function of the analysis. Every definition kills
+// all the other definitions of the same variable.
+func (r *Reaching) Transfer(name string, instructions []models.Instruction, in lattice.UnionMeetSetLattice) lattice.UnionMeetSetLattice {
+	out := utils.Union(in.Set, nil)
+	start, ok := r.start(name, instructions)
+	if !ok {
+		return lattice.UnionMeetSetLattice{Set: out}
+	}
+	for i, inst := range instructions {
+		if inst.Dest == nil {
+			continue
+		}
+		key, ok := r.siteToKey[utils.Site{Block: name, Index: start + i}]
+		if !ok {
+			continue
+		}
+		for _, k := range r.varToKeys[*inst.Dest] {
+			out.Remove(k)
+		}
+		out.Add(key)
+	}
+	return lattice.UnionMeetSetLattice{Set: out}
+}
+
+// start - the index in block name of the first of instructions. The transfer
+// function is given the whole block by df.DF and one instruction at a time by
+// df.InstructionPoints and Build, always a part of the block itself so the
+// distance from the end of its storage tells where it starts.
+func (r *Reaching) start(name string, instructions []models.Instruction) (int, bool) {
+	if len(instructions) == 0 {
+		return 0, true
+	}
+	block := r.nameToBlock[name]
+	i := cap(block) - cap(instructions)
+	if i < 0 || i >= len(block) || &block[i] != &instructions[0] {
+		return 0, false
+	}
+	return i, true
+}
+
+// Def looks up a definition from an element of one of the sets in
+// NameToProgramPoint.
+func (r *Reaching) Def(key string) (Def, bool) {
+	d, ok := r.keyToDef[key]
+	return d, ok
+}
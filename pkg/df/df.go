@@ -1,6 +1,10 @@
 package df
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
@@ -19,45 +23,235 @@ type ProgramPoint[T lattice.Lattice[T]] struct {
 	Out          T
 }
 
+// ErrIterationLimit is returned by Solve when Options.MaxIterations is
+// reached before a fixpoint is.
+var ErrIterationLimit = errors.New("dataflow analysis did not converge")
+
+// ErrUnknownBlock is returned by Solve when the work list or the control flow
+// graph has a block without a program point.
+var ErrUnknownBlock = errors.New("block has no program point")
+
+type Options[T lattice.Lattice[T]] struct {
+	// Widen, when set, is used at loop headers to combine the previous
+	// value of a block's output with the newly computed one. It has to
+	// make sure a value can only change a finite number of times,
+	// otherwise lattices with infinite height never settle.
+	Widen func(previous, next T) T
+	// MaxIterations is how many times the transfer function can be
+	// applied before giving up, zero means there is no limit.
+	MaxIterations int
+}
+
+// Stats describes how much work it took to solve a dataflow problem
+type Stats struct {
+	// TransferCalls is how many times a transfer function was applied to
+	// a block
+	TransferCalls int
+}
+
+// DF solves the dataflow problem without widening or a limit, the lattice
+// needs to have a finite height for it to ever return.
 func DF[T lattice.Lattice[T]](nameToProgramPoint map[string]*ProgramPoint[T],
 	cfg utils.Digraph,
 	initialWorkList []string,
 	direction Direction,
-	transfer func(name string, instructions []models.Instruction, in T) T) {
+	transfer func(name string, instructions []models.Instruction, in T) T) (Stats, error) {
+
+	return Solve(nameToProgramPoint, cfg, initialWorkList, direction, transfer, Options[T]{})
+}
+
+// Solve is DF with Options.
+//
+// Blocks are taken from the work list in the order of a depth first search of
+// the control flow graph: reverse postorder for forward problems and
+// postorder for reverse ones. That way, outside of loops, a block is only
+// visited after everything flowing into it. A block that is already waiting
+// isn't added again.
+//
+// Loop headers are the targets of the back edges found by the same search.
+// Every cycle goes through one of them so widening there is enough to stop
+// any loop.
+func Solve[T lattice.Lattice[T]](nameToProgramPoint map[string]*ProgramPoint[T],
+	cfg utils.Digraph,
+	initialWorkList []string,
+	direction Direction,
+	transfer func(name string, instructions []models.Instruction, in T) T,
+	opts Options[T]) (Stats, error) {
+
+	var stats Stats
+	for _, name := range initialWorkList {
+		if _, ok := nameToProgramPoint[name]; !ok {
+			return stats, fmt.Errorf("%w: %s", ErrUnknownBlock, name)
+		}
+	}
+	var froms []string
+	for from := range cfg {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		for _, name := range append([]string{from}, cfg[from]...) {
+			if _, ok := nameToProgramPoint[name]; !ok {
+				return stats, fmt.Errorf("%w: %s", ErrUnknownBlock, name)
+			}
+		}
+	}
 
-	workList := initialWorkList
-	for len(workList) != 0 {
-		var nextWorkList []string
-		for _, name := range workList {
-			pp := nameToProgramPoint[name]
+	postorder, headers := depthFirst(nameToProgramPoint, cfg, initialWorkList, direction)
+	order := postorder
+	if direction == Forward {
+		order = make([]string, len(postorder))
+		for i, name := range postorder {
+			order[len(postorder)-1-i] = name
+		}
+	}
+	if opts.Widen == nil {
+		headers = nil
+	}
 
-			switch direction {
-			case Forward:
-				for _, pred := range utils.Predecessors(cfg, name) {
-					pp.In = pp.In.Meet(nameToProgramPoint[pred].Out)
-				}
+	workList := newWorkList(order)
+	workList.push(initialWorkList...)
+	for !workList.empty() {
+		if opts.MaxIterations != 0 && stats.TransferCalls >= opts.MaxIterations {
+			return stats, fmt.Errorf("%w after %d iterations", ErrIterationLimit, stats.TransferCalls)
+		}
+		stats.TransferCalls++
+		name := workList.pop()
+		pp := nameToProgramPoint[name]
 
-				before := pp.Out.String()
-				pp.Out = transfer(name, pp.Instructions, pp.In)
-				after := pp.Out.String()
+		switch direction {
+		case Forward:
+			for _, pred := range utils.Predecessors(cfg, name) {
+				pp.In = pp.In.Meet(nameToProgramPoint[pred].Out)
+			}
 
-				if before != after {
-					nextWorkList = append(nextWorkList, utils.Successors(cfg, name)...)
-				}
-			case Reverse:
-				for _, pred := range utils.Successors(cfg, name) {
-					pp.Out = pp.Out.Meet(nameToProgramPoint[pred].In)
-				}
+			out := transfer(name, pp.Instructions, pp.In)
+			if headers.Contains(name) {
+				out = opts.Widen(pp.Out, out)
+			}
+			changed := !out.Equal(pp.Out)
+			pp.Out = out
 
-				before := pp.In.String()
-				pp.In = transfer(name, pp.Instructions, pp.Out)
-				after := pp.In.String()
+			if changed {
+				workList.push(utils.Successors(cfg, name)...)
+			}
+		case Reverse:
+			for _, pred := range utils.Successors(cfg, name) {
+				pp.Out = pp.Out.Meet(nameToProgramPoint[pred].In)
+			}
 
-				if before != after {
-					nextWorkList = append(nextWorkList, utils.Predecessors(cfg, name)...)
-				}
+			in := transfer(name, pp.Instructions, pp.Out)
+			if headers.Contains(name) {
+				in = opts.Widen(pp.In, in)
+			}
+			changed := !in.Equal(pp.In)
+			pp.In = in
+
+			if changed {
+				workList.push(utils.Predecessors(cfg, name)...)
+			}
+		}
+	}
+	return stats, nil
+}
+
+// depthFirst searches the control flow graph, from the start blocks first. A
+// reverse problem starts at the end of the function, but postorder only puts
+// a block after its successors if the search goes forwards, so it starts
+// from the blocks nothing jumps to instead. Blocks that can't be reached from
+// the start are searched from too, in name order, so every block is ordered
+// and every cycle is found. It returns the blocks in postorder and the
+// targets of edges that go back to a block that is still on the search
+// stack.
+func depthFirst[T lattice.Lattice[T]](nameToProgramPoint map[string]*ProgramPoint[T],
+	cfg utils.Digraph,
+	start []string,
+	direction Direction) (postorder []string, headers utils.Set) {
+
+	var names []string
+	for name := range nameToProgramPoint {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if direction == Reverse {
+		var roots []string
+		for _, name := range names {
+			if len(utils.Predecessors(cfg, name)) == 0 {
+				roots = append(roots, name)
+			}
+		}
+		start = append(roots, start...)
+	}
+
+	headers = utils.NewSet()
+	visited := utils.NewSet()
+	onStack := utils.NewSet()
+	var visit func(name string)
+	visit = func(name string) {
+		visited.Add(name)
+		onStack.Add(name)
+		// Going through the successors backwards puts the first one
+		// right after name in reverse postorder. For a loop that's
+		// usually the body, which should settle before anything after
+		// the loop is looked at.
+		succs := utils.Successors(cfg, name)
+		for i := len(succs) - 1; i >= 0; i-- {
+			succ := succs[i]
+			if onStack.Contains(succ) {
+				headers.Add(succ)
+			} else if !visited.Contains(succ) {
+				visit(succ)
+			}
+		}
+		onStack.Remove(name)
+		postorder = append(postorder, name)
+	}
+	for _, name := range append(append([]string{}, start...), names...) {
+		if !visited.Contains(name) {
+			visit(name)
+		}
+	}
+	return postorder, headers
+}
+
+// InstructionPoint holds the facts immediately before (In) and after (Out) a
+// single instruction.
+type InstructionPoint[T lattice.Lattice[T]] struct {
+	Instruction models.Instruction
+	In          T
+	Out         T
+}
+
+// InstructionPoints derives per instruction facts from a solution found by DF.
+// The block level facts are the starting point and the transfer function is
+// replayed one instruction at a time, front to back for forward analyses and
+// back to front for reverse ones. transfer has to be the same function given
+// to DF.
+func InstructionPoints[T lattice.Lattice[T]](nameToProgramPoint map[string]*ProgramPoint[T],
+	direction Direction,
+	transfer func(name string, instructions []models.Instruction, in T) T) map[string][]InstructionPoint[T] {
+
+	out := make(map[string][]InstructionPoint[T])
+	for name, pp := range nameToProgramPoint {
+		points := make([]InstructionPoint[T], len(pp.Instructions))
+		switch direction {
+		case Forward:
+			fact := pp.In
+			for i, inst := range pp.Instructions {
+				next := transfer(name, pp.Instructions[i:i+1], fact)
+				points[i] = InstructionPoint[T]{Instruction: inst, In: fact, Out: next}
+				fact = next
+			}
+		case Reverse:
+			fact := pp.Out
+			for i := len(pp.Instructions) - 1; i >= 0; i-- {
+				next := transfer(name, pp.Instructions[i:i+1], fact)
+				points[i] = InstructionPoint[T]{Instruction: pp.Instructions[i], In: next, Out: fact}
+				fact = next
 			}
 		}
-		workList = nextWorkList
+		out[name] = points
 	}
+	return out
 }
@@ -0,0 +1,73 @@
+package df
+
+import "container/heap"
+
+// workList is a set of blocks that are handed out in passes over a fixed
+// order. A block added behind the last one handed out is part of the current
+// pass, a block added in front of it waits for the next pass. Always taking
+// the first block in the order instead can go back to the start of a long
+// chain of loops every time one of them changes.
+type workList struct {
+	order  []string
+	rank   map[string]int
+	queued []bool
+	last   int
+	pass   rankHeap
+	next   rankHeap
+}
+
+func newWorkList(order []string) *workList {
+	w := &workList{
+		order:  order,
+		rank:   make(map[string]int),
+		queued: make([]bool, len(order)),
+		last:   -1,
+	}
+	for i, name := range order {
+		w.rank[name] = i
+	}
+	return w
+}
+
+func (w *workList) push(names ...string) {
+	for _, name := range names {
+		r, ok := w.rank[name]
+		if !ok || w.queued[r] {
+			continue
+		}
+		w.queued[r] = true
+		if r > w.last {
+			heap.Push(&w.pass, r)
+		} else {
+			heap.Push(&w.next, r)
+		}
+	}
+}
+
+func (w *workList) pop() string {
+	if len(w.pass) == 0 {
+		w.pass, w.next = w.next, w.pass
+	}
+	r := heap.Pop(&w.pass).(int)
+	w.queued[r] = false
+	w.last = r
+	return w.order[r]
+}
+
+func (w *workList) empty() bool {
+	return len(w.pass) == 0 && len(w.next) == 0
+}
+
+// rankHeap is a min heap of positions in workList.order
+type rankHeap []int
+
+func (h rankHeap) Len() int            { return len(h) }
+func (h rankHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h rankHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *rankHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *rankHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -1,25 +1,30 @@
 package dominators
 
 import (
-	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
-	dfutils "aaronstgeorge.com/self-guided-cs-1620/pkg/df/utils"
-	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
-	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"errors"
+	"fmt"
+
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
 )
 
-func Front(namesInOrder []string, cfg utils.Digraph, domTree utils.Digraph) map[string]utils.Set {
-	immediateDominator := func(node string) string {
-		if imDdom, ok := immediateDominatorFromTree(node, domTree); ok {
-			return imDdom
+// ErrMalformedTree is returned by Front when domTree isn't the dominator tree
+// of cfg, a block has more than one parent or a walk up the tree from a
+// predecessor of a join point doesn't reach the join point's parent.
+var ErrMalformedTree = errors.New("malformed dominator tree")
+
+func Front(namesInOrder []string, cfg utils.Digraph, domTree utils.Digraph) (map[string]utils.Set, error) {
+	parent := make(map[string]string)
+	for dom, subs := range domTree {
+		for _, sub := range subs {
+			if _, ok := parent[sub]; ok {
+				return nil, fmt.Errorf("%w: %s has more than one immediate dominator", ErrMalformedTree, sub)
+			}
+			parent[sub] = dom
 		}
-		// The only thing that won't have an immediate dominator will be
-		// the entry point. While running up the dominators from a
-		// predecessor of a join point we should not need to ask what
-		// the immediate dominator of the entry point is because that
-		// will always be a strict dominator of any join point, so our
-		// while loop will short circut.
-		panic("assertion error: no immediate dominator")
+	}
+	reachable := func(name string) bool {
+		_, ok := parent[name]
+		return ok || name == namesInOrder[0]
 	}
 
 	// initialization
@@ -30,101 +35,153 @@ func Front(namesInOrder []string, cfg utils.Digraph, domTree utils.Digraph) map[
 
 	// Compute dominance frontier
 	// Engineering a Compiler pp. 499
+	predecessors := utils.Reverse(cfg)
 	for _, name := range namesInOrder {
-		preds := utils.Predecessors(cfg, name)
-		if len(preds) >= 2 {
+		preds := predecessors[name]
+		if len(preds) >= 2 && reachable(name) {
 			joinPoint := name
 			for _, pred := range preds {
+				// An edge from a block that can't be reached doesn't
+				// make anything a join point
+				if !reachable(pred) {
+					continue
+				}
 				runner := pred
-				if idom, ok := immediateDominatorFromTree(joinPoint, domTree); ok {
+				if idom, ok := parent[joinPoint]; ok {
 					for runner != idom {
-						nameToFront[runner] = utils.Union(nameToFront[runner], utils.NewSet(joinPoint))
-						runner = immediateDominator(runner)
+						front, ok := nameToFront[runner]
+						if !ok {
+							return nil, fmt.Errorf("%w: %s isn't a block", ErrMalformedTree, runner)
+						}
+						front.Add(joinPoint)
+						// The entry strictly dominates every join
+						// point, so the runner stops before it
+						// needs the entry's immediate dominator.
+						if runner, ok = parent[runner]; !ok {
+							return nil, fmt.Errorf("%w: %s doesn't dominate %s", ErrMalformedTree, idom, pred)
+						}
 					}
 				}
 			}
 		}
 	}
-	return nameToFront
+	return nameToFront, nil
 }
 
-func Tree(namesInOrder []string, cfg utils.Digraph, nameToDominators map[string]utils.Set) utils.Digraph {
+// Tree - the dominator tree, there is an edge from every reachable block's
+// immediate dominator to it
+func Tree(namesInOrder []string, cfg utils.Digraph) utils.Digraph {
+	idoms := ImmediateDominators(namesInOrder, cfg)
 	out := make(utils.Digraph)
-	for _, dom := range namesInOrder {
-		for _, sub := range namesInOrder {
-			if isIDom(dom, sub, cfg, nameToDominators) {
-				out[dom] = append(out[dom], sub)
-			}
+	for _, name := range namesInOrder {
+		if idom, ok := idoms[name]; ok {
+			out[idom] = append(out[idom], name)
 		}
 	}
 	return out
 }
 
-func Dominators(namesInOrder []string, nameToBlock map[string][]models.Instruction, cfg utils.Digraph) (nameToDominators map[string]utils.Set) {
-	nameToProgramPoint := dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.IntersetMeetSetLattice {
-		set := make(utils.Set)
-		set.Add(namesInOrder...)
-		return lattice.IntersetMeetSetLattice{Set: set}
-	})
-	// Apply boundary condition
-	nameToProgramPoint[namesInOrder[0]].In = lattice.IntersetMeetSetLattice{Set: make(utils.Set)}
-
-	workList := []string{namesInOrder[0]}
-	df.DF(nameToProgramPoint,
-		cfg,
-		workList,
-		df.Forward,
-		func(name string, _ []models.Instruction, in lattice.IntersetMeetSetLattice) lattice.IntersetMeetSetLattice {
-			this := make(utils.Set)
-			this.Add(name)
-			return lattice.IntersetMeetSetLattice{Set: utils.Union(in.Set, this)}
-		})
-
+// Dominators - the blocks that dominate each block, found by walking up the
+// immediate dominators. A block that can't be reached from the entry is only
+// dominated by itself.
+func Dominators(namesInOrder []string, cfg utils.Digraph) (nameToDominators map[string]utils.Set) {
+	idoms := ImmediateDominators(namesInOrder, cfg)
 	nameToDominators = make(map[string]utils.Set)
-	for name, pp := range nameToProgramPoint {
-		nameToDominators[name] = pp.Out.Set
+	for _, name := range namesInOrder {
+		doms := utils.NewSet(name)
+		for dom, ok := idoms[name]; ok; dom, ok = idoms[dom] {
+			doms.Add(dom)
+		}
+		nameToDominators[name] = doms
 	}
-
 	return nameToDominators
 }
 
-// immediateDominatorFromDoms - immediate dominator calculated from the dominators set
-func immediateDominatorFromDoms(start string, cfg utils.Digraph, dominators utils.Set) (string, bool) {
-	s := make(utils.Set)
-	s.Add(start)
-	dominators = utils.Sub(dominators, s)
-	immediateDom := ""
-	found := false
-	utils.WalkUp(cfg, start, func(name string) bool {
-		if dominators.Contains(name) {
-			immediateDom = name
-			found = true
-			return true
+// ImmediateDominators - the immediate dominator of every block that can be
+// reached from the entry, except the entry which has none.
+//
+// Cooper, Harvey and Kennedy "A Simple, Fast Dominance Algorithm". Blocks are
+// numbered in reverse postorder and the immediate dominator of a block is the
+// nearest common ancestor, in the tree built so far, of its predecessors.
+func ImmediateDominators(namesInOrder []string, cfg utils.Digraph) map[string]string {
+	if len(namesInOrder) == 0 {
+		return make(map[string]string)
+	}
+	order := reversePostorder(namesInOrder[0], cfg)
+	number := make(map[string]int)
+	for i, name := range order {
+		number[name] = i
+	}
+	predecessors := utils.Reverse(cfg)
+
+	const undefined = -1
+	idom := make([]int, len(order))
+	for i := range idom {
+		idom[i] = undefined
+	}
+	idom[0] = 0
+
+	intersect := func(a, b int) int {
+		for a != b {
+			for a > b {
+				a = idom[a]
+			}
+			for b > a {
+				b = idom[b]
+			}
 		}
-		return false
-	})
-	return immediateDom, found
-}
+		return a
+	}
 
-func isIDom(dom, sub string, cfg utils.Digraph, nameToDominators map[string]utils.Set) bool {
-	if dom == sub {
-		return false
+	for changed := true; changed; {
+		changed = false
+		for i := 1; i < len(order); i++ {
+			newIDom := undefined
+			for _, pred := range predecessors[order[i]] {
+				p, ok := number[pred]
+				if !ok || idom[p] == undefined {
+					continue
+				}
+				if newIDom == undefined {
+					newIDom = p
+				} else {
+					newIDom = intersect(p, newIDom)
+				}
+			}
+			if idom[i] != newIDom {
+				idom[i] = newIDom
+				changed = true
+			}
+		}
 	}
-	if !nameToDominators[sub].Contains(dom) {
-		return false
+
+	out := make(map[string]string)
+	for i := 1; i < len(order); i++ {
+		out[order[i]] = order[idom[i]]
 	}
-	idom, ok := immediateDominatorFromDoms(sub, cfg, nameToDominators[sub])
-	return ok && idom == dom
+	return out
 }
 
-// immediateDominatorFromTree - immediate dominators from the dominator tree
-func immediateDominatorFromTree(name string, domTree utils.Digraph) (string, bool) {
-	preds := utils.Predecessors(domTree, name)
-	if len(preds) > 1 {
-		panic("assertion error: dom tree malformed")
+// reversePostorder - the blocks that can be reached from entry, each one
+// after all of its predecessors except along back edges
+func reversePostorder(entry string, cfg utils.Digraph) []string {
+	visited := utils.NewSet()
+	var postorder []string
+	var visit func(name string)
+	visit = func(name string) {
+		visited.Add(name)
+		for _, succ := range utils.Successors(cfg, name) {
+			if !visited.Contains(succ) {
+				visit(succ)
+			}
+		}
+		postorder = append(postorder, name)
 	}
-	if len(preds) == 1 {
-		return preds[0], true
+	visit(entry)
+
+	order := make([]string, len(postorder))
+	for i, name := range postorder {
+		order[len(postorder)-1-i] = name
 	}
-	return "", false
+	return order
 }
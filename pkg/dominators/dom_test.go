@@ -0,0 +1,144 @@
+package dominators
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	dfutils "aaronstgeorge.com/self-guided-cs-1620/pkg/df/utils"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/text"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// dataflowDominators is how dominators were computed before Cooper, Harvey
+// and Kennedy, a forward dataflow problem where a block is dominated by
+// itself and by whatever dominates all of its predecessors. Blocks that
+// can't be reached are never visited and stay dominated by everything.
+func dataflowDominators(namesInOrder []string, nameToBlock map[string][]models.Instruction, cfg utils.Digraph) (map[string]utils.Set, error) {
+	u := utils.NewUniverse(namesInOrder...)
+	nameToProgramPoint := dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.IntersectMeetBitSetLattice {
+		return lattice.IntersectMeetBitSetLattice{BitSet: u.Full()}
+	})
+	// Apply boundary condition
+	nameToProgramPoint[namesInOrder[0]].In = lattice.IntersectMeetBitSetLattice{BitSet: u.Empty()}
+
+	workList := []string{namesInOrder[0]}
+	_, err := df.DF(nameToProgramPoint,
+		cfg,
+		workList,
+		df.Forward,
+		func(name string, _ []models.Instruction, in lattice.IntersectMeetBitSetLattice) lattice.IntersectMeetBitSetLattice {
+			out := in.Copy()
+			out.Add(name)
+			return lattice.IntersectMeetBitSetLattice{BitSet: out}
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	nameToDominators := make(map[string]utils.Set)
+	for name, pp := range nameToProgramPoint {
+		nameToDominators[name] = pp.Out.Set()
+	}
+	return nameToDominators, nil
+}
+
+// dataflowTree is the dominator tree the way it was built from the dataflow
+// dominators, the immediate dominator of a block is the first of its
+// dominators found walking up the control flow graph.
+func dataflowTree(namesInOrder []string, cfg utils.Digraph, nameToDominators map[string]utils.Set) utils.Digraph {
+	out := make(utils.Digraph)
+	for _, sub := range namesInOrder {
+		strict := utils.Sub(nameToDominators[sub], utils.NewSet(sub))
+		utils.WalkUp(cfg, sub, func(name string) bool {
+			if strict.Contains(name) {
+				out[name] = append(out[name], sub)
+				return true
+			}
+			return false
+		})
+	}
+	return out
+}
+
+// TestDataflowAgrees checks Dominators and Tree against the dataflow
+// implementation on every function of every test program. Only blocks that
+// can be reached are compared, the two disagree about the rest on purpose.
+func TestDataflowAgrees(t *testing.T) {
+	var paths []string
+	for _, pattern := range []string{"../../test/*/*.bril", "../../test/*/*/*.bril"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no test programs found")
+	}
+
+	functions := 0
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Some tests are programs that are meant to be rejected
+		prog, err := text.Parse(string(src))
+		if err != nil {
+			continue
+		}
+		for _, function := range prog.Functions {
+			namesInOrder, nameToBlock := utils.BasicBlocks(function)
+			if len(namesInOrder) == 0 {
+				continue
+			}
+			cfg, err := utils.CFG(namesInOrder, nameToBlock)
+			if err != nil {
+				continue
+			}
+			functions++
+			where := path + " @" + function.Name
+
+			want, err := dataflowDominators(namesInOrder, nameToBlock, cfg)
+			if err != nil {
+				t.Fatalf("%s: %v", where, err)
+			}
+			got := Dominators(namesInOrder, cfg)
+			reachable := utils.NewSet(namesInOrder[0])
+			for name := range ImmediateDominators(namesInOrder, cfg) {
+				reachable.Add(name)
+			}
+			for _, name := range namesInOrder {
+				if reachable.Contains(name) && !got[name].Equal(want[name]) {
+					t.Errorf("%s: dominators of %s are %v, the dataflow implementation says %v",
+						where, name, got[name], want[name])
+				}
+			}
+
+			wantTree := dataflowTree(namesInOrder, cfg, want)
+			gotTree := Tree(namesInOrder, cfg)
+			for _, name := range namesInOrder {
+				if !reachable.Contains(name) {
+					continue
+				}
+				var wantSubs []string
+				for _, sub := range wantTree[name] {
+					if reachable.Contains(sub) {
+						wantSubs = append(wantSubs, sub)
+					}
+				}
+				if !utils.NewSet(gotTree[name]...).Equal(utils.NewSet(wantSubs...)) {
+					t.Errorf("%s: %s immediately dominates %v, the dataflow implementation says %v",
+						where, name, gotTree[name], wantSubs)
+				}
+			}
+		}
+	}
+	if functions == 0 {
+		t.Fatal("no functions compared")
+	}
+}
@@ -0,0 +1,64 @@
+package dominators
+
+import (
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// Exit is the virtual exit post dominance is computed from. A function can
+// return from several blocks, every one of them is joined to Exit. It isn't a
+// label that can be written in the text format so it can't be a block name.
+const Exit = "<exit>"
+
+// Reverse - cfg with its edges turned around and Exit as the entry. The
+// blocks without successors, the ones that return, are the successors of
+// Exit. Blocks that never return, an infinite loop, can't be reached from
+// Exit so they are only post dominated by themselves.
+func Reverse(namesInOrder []string, cfg utils.Digraph) (reverseNamesInOrder []string, reverse utils.Digraph) {
+	reverse = utils.Reverse(cfg)
+	reverseNamesInOrder = []string{Exit}
+	for i := len(namesInOrder) - 1; i >= 0; i-- {
+		name := namesInOrder[i]
+		reverseNamesInOrder = append(reverseNamesInOrder, name)
+		if len(utils.Successors(cfg, name)) == 0 {
+			reverse[Exit] = append(reverse[Exit], name)
+		}
+	}
+	return reverseNamesInOrder, reverse
+}
+
+// PostDominators - the blocks that are on every path from each block to
+// Exit, Exit included
+func PostDominators(namesInOrder []string, cfg utils.Digraph) map[string]utils.Set {
+	return Dominators(Reverse(namesInOrder, cfg))
+}
+
+// PostTree - the post dominator tree, rooted at Exit
+func PostTree(namesInOrder []string, cfg utils.Digraph) utils.Digraph {
+	return Tree(Reverse(namesInOrder, cfg))
+}
+
+// PostFront - the post dominance frontier of every block, the blocks where
+// one successor leads to it and another can avoid it
+func PostFront(namesInOrder []string, cfg utils.Digraph, postTree utils.Digraph) (map[string]utils.Set, error) {
+	reverseNamesInOrder, reverse := Reverse(namesInOrder, cfg)
+	return Front(reverseNamesInOrder, reverse, postTree)
+}
+
+// ControlDependence - the control dependence graph, there is an edge from a
+// block to every block whose execution depends on which way it branches.
+// They are the blocks with it in their post dominance frontier.
+func ControlDependence(namesInOrder []string, cfg utils.Digraph) (utils.Digraph, error) {
+	front, err := PostFront(namesInOrder, cfg, PostTree(namesInOrder, cfg))
+	if err != nil {
+		return nil, err
+	}
+	out := make(utils.Digraph)
+	for _, name := range namesInOrder {
+		for _, branch := range namesInOrder {
+			if front[name].Contains(branch) {
+				out[branch] = append(out[branch], name)
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,258 @@
+// Package gvn implements dominator based global value numbering for
+// functions in SSA form. It's the global version of cmd/lvn, instead of a
+// single block the value table follows the dominator tree so anything
+// computed in a dominating block can be reused.
+package gvn
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/dominators"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ops"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ssa"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// scope is a value table for one block in the dominator tree. Lookups fall
+// back to the tables of the dominating blocks.
+type scope struct {
+	parent *scope
+	table  map[string]string
+}
+
+func (s *scope) lookup(key string) (string, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.table[key]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+type numberer struct {
+	nameToBlock map[string][]models.Instruction
+	cfg         utils.Digraph
+	tree        utils.Digraph
+	// The value number of a variable is the name of the first variable
+	// that was found to hold that value. In SSA form a variable is never
+	// reassigned so this never needs to change.
+	vn      map[string]string
+	visited utils.Set
+}
+
+// GVN removes computations from function that were already computed in a
+// dominating block (or earlier in the same block). Uses of the removed
+// variables are rewritten to the variable holding the earlier computation.
+// Copies (id) and meaningless phi nodes, the ones whose arguments all have
+// the same value, are removed the same way.
+func GVN(function models.Function) (models.Function, error) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	if len(namesInOrder) == 0 {
+		return function, nil
+	}
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		return function, err
+	}
+	return Number(function, namesInOrder, nameToBlock, cfg, dominators.Tree(namesInOrder, cfg))
+}
+
+// Number is GVN for when the blocks of function, its control flow graph and
+// its dominator tree are already known.
+func Number(function models.Function, namesInOrder []string, nameToBlock map[string][]models.Instruction, cfg utils.Digraph, tree utils.Digraph) (models.Function, error) {
+	if len(namesInOrder) == 0 {
+		return function, nil
+	}
+	n := &numberer{
+		nameToBlock: nameToBlock,
+		cfg:         cfg,
+		tree:        tree,
+		vn:          make(map[string]string),
+		visited:     utils.NewSet(),
+	}
+	n.number(namesInOrder[0], nil)
+
+	// Blocks that can't be reached aren't in the dominator tree, they
+	// still need to refer to variables that exist.
+	for _, name := range namesInOrder {
+		if !n.visited.Contains(name) {
+			for i, inst := range nameToBlock[name] {
+				nameToBlock[name][i] = n.rewriteArgs(inst)
+			}
+		}
+	}
+
+	function.Instrs = utils.FlattenBlocks(namesInOrder, nameToBlock)
+	return function, nil
+}
+
+func (n *numberer) value(name string) string {
+	if v, ok := n.vn[name]; ok {
+		return v
+	}
+	return name
+}
+
+func (n *numberer) rewriteArgs(inst models.Instruction) models.Instruction {
+	if len(inst.Args) == 0 {
+		return inst
+	}
+	args := make([]string, len(inst.Args))
+	for i, arg := range inst.Args {
+		args[i] = n.value(arg)
+	}
+	inst.Args = args
+	return inst
+}
+
+func (n *numberer) number(name string, parent *scope) {
+	n.visited.Add(name)
+	s := &scope{parent: parent, table: make(map[string]string)}
+	// Redundant phi nodes can only be found within a block, phi nodes in
+	// different blocks choose between different edges.
+	phis := make(map[string]string)
+
+	var out []models.Instruction
+	for _, inst := range n.nameToBlock[name] {
+		if inst.Op == nil {
+			out = append(out, inst)
+			continue
+		}
+
+		if *inst.Op == "phi" {
+			if v, ok := n.meaninglessPhi(inst); ok {
+				n.vn[*inst.Dest] = v
+				continue
+			}
+			key := n.phiKey(inst)
+			if v, ok := phis[key]; ok {
+				n.vn[*inst.Dest] = v
+				continue
+			}
+			phis[key] = *inst.Dest
+			n.vn[*inst.Dest] = *inst.Dest
+			// The arguments are rewritten by the predecessors
+			out = append(out, inst)
+			continue
+		}
+
+		inst = n.rewriteArgs(inst)
+		if inst.Dest == nil {
+			out = append(out, inst)
+			continue
+		}
+
+		if *inst.Op == "id" && len(inst.Args) == 1 {
+			n.vn[*inst.Dest] = inst.Args[0]
+			continue
+		}
+		// Anything impure gets a fresh value number, constants are
+		// numbered like any other pure operation.
+		if *inst.Op != "const" && !ops.Pure.Contains(*inst.Op) {
+			n.vn[*inst.Dest] = *inst.Dest
+			out = append(out, inst)
+			continue
+		}
+
+		key := expressionKey(inst)
+		if v, ok := s.lookup(key); ok {
+			n.vn[*inst.Dest] = v
+			continue
+		}
+		s.table[key] = *inst.Dest
+		n.vn[*inst.Dest] = *inst.Dest
+		out = append(out, inst)
+	}
+	n.nameToBlock[name] = out
+
+	// Phi arguments coming from this block are uses at the end of this
+	// block, so they need to use the value numbers from here.
+	for _, succ := range utils.Successors(n.cfg, name) {
+		block := n.nameToBlock[succ]
+		for i, inst := range block {
+			if inst.Op == nil || *inst.Op != "phi" {
+				continue
+			}
+			args := make([]string, len(inst.Args))
+			copy(args, inst.Args)
+			for j, label := range inst.Labels {
+				if label == name && j < len(args) {
+					args[j] = n.value(args[j])
+				}
+			}
+			inst.Args = args
+			block[i] = inst
+		}
+	}
+
+	for _, child := range utils.Successors(n.tree, name) {
+		n.number(child, s)
+	}
+}
+
+// meaninglessPhi - a phi whose arguments all have the same value number is
+// just a copy of that value. Arguments referring to the phi itself (a value
+// that doesn't change around a loop) don't count.
+func (n *numberer) meaninglessPhi(inst models.Instruction) (string, bool) {
+	value := ""
+	for _, arg := range inst.Args {
+		if arg == ssa.Undefined {
+			return "", false
+		}
+		v := n.value(arg)
+		if v == *inst.Dest {
+			continue
+		}
+		if value != "" && v != value {
+			return "", false
+		}
+		value = v
+	}
+	return value, value != ""
+}
+
+func (n *numberer) phiKey(inst models.Instruction) string {
+	var parts []string
+	for i, label := range inst.Labels {
+		arg := ""
+		if i < len(inst.Args) {
+			arg = n.value(inst.Args[i])
+		}
+		parts = append(parts, label+":"+arg)
+	}
+	sort.Strings(parts)
+	key := inst.Type.String()
+	for _, p := range parts {
+		key += " " + p
+	}
+	return key
+}
+
+// expressionKey - the same text for every instruction that computes the same
+// value: its operation, type, literal value and arguments, with the arguments
+// sorted when their order doesn't matter.
+func expressionKey(inst models.Instruction) string {
+	args := inst.Args
+	if ops.Commutative.Contains(*inst.Op) {
+		args = make([]string, len(inst.Args))
+		copy(args, inst.Args)
+		sort.Strings(args)
+	}
+	parts := []string{*inst.Op}
+	if inst.Type != nil {
+		parts = append(parts, inst.Type.String())
+	}
+	if v := inst.Value; v != nil {
+		if v.Float != nil {
+			parts = append(parts, strconv.FormatFloat(*v.Float, 'g', -1, 64))
+		}
+		if v.Bool != nil {
+			parts = append(parts, strconv.FormatBool(*v.Bool))
+		}
+	}
+	parts = append(parts, args...)
+	return strings.Join(parts, " ")
+}
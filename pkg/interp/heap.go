@@ -0,0 +1,69 @@
+package interp
+
+import (
+	"fmt"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ops"
+)
+
+// heap models the memory extension. Every allocation gets its own base so
+// pointer arithmetic can never wander from one allocation into another, any
+// offset outside of an allocation is an error.
+type heap struct {
+	storage  map[int][]*ops.Value
+	nextBase int
+}
+
+func newHeap() *heap {
+	return &heap{storage: make(map[int][]*ops.Value)}
+}
+
+func (h *heap) alloc(amount int64) (int, error) {
+	if amount <= 0 {
+		return 0, fmt.Errorf("cannot allocate %d entries", amount)
+	}
+	base := h.nextBase
+	h.nextBase++
+	h.storage[base] = make([]*ops.Value, amount)
+	return base, nil
+}
+
+func (h *heap) free(p ops.Pointer) error {
+	if _, ok := h.storage[p.Base]; !ok || p.Offset != 0 {
+		return fmt.Errorf("Tried to free illegal memory location base: %d, offset: %d. Offset must be 0.", p.Base, p.Offset)
+	}
+	delete(h.storage, p.Base)
+	return nil
+}
+
+func (h *heap) cell(p ops.Pointer) (**ops.Value, error) {
+	data, ok := h.storage[p.Base]
+	if !ok || p.Offset < 0 || p.Offset >= int64(len(data)) {
+		return nil, fmt.Errorf("Uninitialized heap location %d and/or illegal offset %d", p.Base, p.Offset)
+	}
+	return &data[p.Offset], nil
+}
+
+func (h *heap) write(p ops.Pointer, v ops.Value) error {
+	c, err := h.cell(p)
+	if err != nil {
+		return err
+	}
+	*c = &v
+	return nil
+}
+
+func (h *heap) read(p ops.Pointer) (ops.Value, error) {
+	c, err := h.cell(p)
+	if err != nil {
+		return ops.Value{}, err
+	}
+	if *c == nil {
+		return ops.Value{}, fmt.Errorf("Pointer %d+%d points to uninitialized data", p.Base, p.Offset)
+	}
+	return **c, nil
+}
+
+func (h *heap) empty() bool {
+	return len(h.storage) == 0
+}
@@ -0,0 +1,490 @@
+// Package interp is a Bril interpreter that works directly on models.Program.
+// It supports the core language along with the float, memory and SSA
+// extensions and tries to behave exactly like the reference TypeScript
+// interpreter (brili), including its error messages.
+package interp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ops"
+)
+
+var (
+	intType   = primitive("int")
+	boolType  = primitive("bool")
+	floatType = primitive("float")
+)
+
+func primitive(name string) models.Type {
+	return models.Type{Primitive: &name}
+}
+
+// Run interprets prog starting at @main, which is passed args. Anything
+// printed by the program is written to out. The number of instructions
+// executed is returned even when the program fails.
+func Run(prog models.Program, args []string, out io.Writer) (int, error) {
+	i := &interpreter{
+		funcs: make(map[string]models.Function),
+		heap:  newHeap(),
+		out:   out,
+	}
+	for _, function := range prog.Functions {
+		if _, ok := i.funcs[function.Name]; ok {
+			return 0, fmt.Errorf("multiple functions named %s", function.Name)
+		}
+		i.funcs[function.Name] = function
+	}
+
+	main, ok := i.funcs["main"]
+	if !ok {
+		return 0, fmt.Errorf("no main function")
+	}
+	if len(args) != len(main.Args) {
+		return 0, fmt.Errorf("mismatched main argument arity: expected %d; got %d", len(main.Args), len(args))
+	}
+	env := make(map[string]ops.Value)
+	for idx, arg := range main.Args {
+		v, err := parseArg(args[idx], *arg.Type)
+		if err != nil {
+			return 0, err
+		}
+		env[arg.Name] = v
+	}
+
+	if _, err := i.call(main, env); err != nil {
+		return i.count, err
+	}
+	if !i.heap.empty() {
+		return i.count, fmt.Errorf("Some memory locations have not been freed by end of execution.")
+	}
+	return i.count, nil
+}
+
+type interpreter struct {
+	funcs map[string]models.Function
+	heap  *heap
+	out   io.Writer
+	count int
+}
+
+// frame is the state of a single function invocation. The last label is
+// needed to figure out which argument a phi node should take.
+type frame struct {
+	env       map[string]ops.Value
+	curLabel  *string
+	lastLabel *string
+}
+
+// call runs function to completion in env. The returned value is nil for
+// functions that don't return anything.
+func (i *interpreter) call(function models.Function, env map[string]ops.Value) (*ops.Value, error) {
+	labelToIdx := make(map[string]int)
+	for idx, inst := range function.Instrs {
+		if inst.Label != nil {
+			labelToIdx[*inst.Label] = idx
+		}
+	}
+
+	f := &frame{env: env}
+	pc := 0
+	for pc < len(function.Instrs) {
+		inst := function.Instrs[pc]
+		pc++
+
+		if inst.Op == nil {
+			if inst.Label != nil {
+				f.lastLabel = f.curLabel
+				f.curLabel = inst.Label
+			}
+			continue
+		}
+		i.count++
+
+		switch *inst.Op {
+		case "jmp", "br":
+			target, err := i.branchTarget(inst, f)
+			if err != nil {
+				return nil, err
+			}
+			idx, ok := labelToIdx[target]
+			if !ok {
+				return nil, fmt.Errorf("label %s not found", target)
+			}
+			pc = idx
+		case "phi":
+			// The phis at the start of a block all read their arguments
+			// before any of them is assigned
+			end := pc
+			for end < len(function.Instrs) && isPhi(function.Instrs[end]) {
+				end++
+			}
+			if err := evalPhis(function.Instrs[pc-1:end], f); err != nil {
+				return nil, err
+			}
+			i.count += end - pc
+			pc = end
+		case "ret":
+			switch len(inst.Args) {
+			case 0:
+				return nil, nil
+			case 1:
+				v, err := get(f.env, inst.Args[0])
+				if err != nil {
+					return nil, err
+				}
+				return &v, nil
+			default:
+				return nil, fmt.Errorf("ret takes 0 or 1 argument(s); got %d", len(inst.Args))
+			}
+		default:
+			if err := i.eval(inst, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (i *interpreter) branchTarget(inst models.Instruction, f *frame) (string, error) {
+	if *inst.Op == "jmp" {
+		if err := checkCount(inst, "label", len(inst.Labels), 1); err != nil {
+			return "", err
+		}
+		return inst.Labels[0], nil
+	}
+	if err := checkArgs(inst, 1); err != nil {
+		return "", err
+	}
+	if err := checkCount(inst, "label", len(inst.Labels), 2); err != nil {
+		return "", err
+	}
+	cond, err := getTyped(inst, f.env, 0, boolType)
+	if err != nil {
+		return "", err
+	}
+	if cond.Bool {
+		return inst.Labels[0], nil
+	}
+	return inst.Labels[1], nil
+}
+
+// eval evaluates every instruction that doesn't transfer control
+func (i *interpreter) eval(inst models.Instruction, f *frame) error {
+	op := *inst.Op
+
+	if fn, ok := ops.Ints[op]; ok {
+		return i.binary(inst, f, intType, fn)
+	}
+	if fn, ok := ops.Floats[op]; ok {
+		return i.binary(inst, f, floatType, fn)
+	}
+
+	switch op {
+	case "const":
+		v, err := ops.Literal(inst)
+		if err != nil {
+			return err
+		}
+		return set(inst, f.env, v)
+	case "id":
+		if err := checkArgs(inst, 1); err != nil {
+			return err
+		}
+		v, err := get(f.env, inst.Args[0])
+		if err != nil {
+			return err
+		}
+		return set(inst, f.env, v)
+	case "not":
+		if err := checkArgs(inst, 1); err != nil {
+			return err
+		}
+		v, err := getTyped(inst, f.env, 0, boolType)
+		if err != nil {
+			return err
+		}
+		return set(inst, f.env, ops.BoolValue(!v.Bool))
+	case "and", "or":
+		if err := checkArgs(inst, 2); err != nil {
+			return err
+		}
+		a, err := getTyped(inst, f.env, 0, boolType)
+		if err != nil {
+			return err
+		}
+		b, err := getTyped(inst, f.env, 1, boolType)
+		if err != nil {
+			return err
+		}
+		if op == "and" {
+			return set(inst, f.env, ops.BoolValue(a.Bool && b.Bool))
+		}
+		return set(inst, f.env, ops.BoolValue(a.Bool || b.Bool))
+	case "print":
+		var strs []string
+		for _, arg := range inst.Args {
+			v, err := get(f.env, arg)
+			if err != nil {
+				return err
+			}
+			strs = append(strs, v.String())
+		}
+		_, err := fmt.Fprintln(i.out, strings.Join(strs, " "))
+		return err
+	case "nop":
+		return nil
+	case "call":
+		return i.evalCall(inst, f)
+	case "alloc":
+		if err := checkArgs(inst, 1); err != nil {
+			return err
+		}
+		amount, err := getTyped(inst, f.env, 0, intType)
+		if err != nil {
+			return err
+		}
+		if inst.Type == nil || inst.Type.Parameterized == nil {
+			return fmt.Errorf("alloc must have a pointer type")
+		}
+		base, err := i.heap.alloc(amount.Int)
+		if err != nil {
+			return err
+		}
+		return set(inst, f.env, ops.PtrValue(ops.Pointer{Base: base, Type: inst.Type.Parameterized.Type}))
+	case "free":
+		if err := checkArgs(inst, 1); err != nil {
+			return err
+		}
+		p, err := getPtr(inst, f.env, 0)
+		if err != nil {
+			return err
+		}
+		return i.heap.free(p)
+	case "store":
+		if err := checkArgs(inst, 2); err != nil {
+			return err
+		}
+		p, err := getPtr(inst, f.env, 0)
+		if err != nil {
+			return err
+		}
+		v, err := getTyped(inst, f.env, 1, p.Type)
+		if err != nil {
+			return err
+		}
+		return i.heap.write(p, v)
+	case "load":
+		if err := checkArgs(inst, 1); err != nil {
+			return err
+		}
+		p, err := getPtr(inst, f.env, 0)
+		if err != nil {
+			return err
+		}
+		v, err := i.heap.read(p)
+		if err != nil {
+			return err
+		}
+		return set(inst, f.env, v)
+	case "ptradd":
+		if err := checkArgs(inst, 2); err != nil {
+			return err
+		}
+		p, err := getPtr(inst, f.env, 0)
+		if err != nil {
+			return err
+		}
+		offset, err := getTyped(inst, f.env, 1, intType)
+		if err != nil {
+			return err
+		}
+		p.Offset += offset.Int
+		return set(inst, f.env, ops.PtrValue(p))
+	}
+	return fmt.Errorf("unhandled opcode %s", op)
+}
+
+func (i *interpreter) binary(inst models.Instruction, f *frame, t models.Type, fn func(a, b ops.Value) (ops.Value, error)) error {
+	if err := checkArgs(inst, 2); err != nil {
+		return err
+	}
+	a, err := getTyped(inst, f.env, 0, t)
+	if err != nil {
+		return err
+	}
+	b, err := getTyped(inst, f.env, 1, t)
+	if err != nil {
+		return err
+	}
+	v, err := fn(a, b)
+	if err != nil {
+		return err
+	}
+	return set(inst, f.env, v)
+}
+
+func (i *interpreter) evalCall(inst models.Instruction, f *frame) error {
+	if err := checkCount(inst, "function", len(inst.Funcs), 1); err != nil {
+		return err
+	}
+	function, ok := i.funcs[inst.Funcs[0]]
+	if !ok {
+		return fmt.Errorf("function %s not found", inst.Funcs[0])
+	}
+	if len(inst.Args) != len(function.Args) {
+		return fmt.Errorf("function expected %d arguments, got %d", len(function.Args), len(inst.Args))
+	}
+
+	env := make(map[string]ops.Value)
+	for idx, param := range function.Args {
+		v, err := get(f.env, inst.Args[idx])
+		if err != nil {
+			return err
+		}
+		if !ops.HasType(v, *param.Type) {
+			return fmt.Errorf("function argument type mismatch")
+		}
+		env[param.Name] = v
+	}
+
+	ret, err := i.call(function, env)
+	if err != nil {
+		return err
+	}
+
+	if inst.Dest == nil {
+		if ret != nil {
+			return fmt.Errorf("unexpected value returned without destination")
+		}
+		if function.Type != nil {
+			return fmt.Errorf("non-void function (type: %s) doesn't return anything", function.Type)
+		}
+		return nil
+	}
+
+	if inst.Type == nil {
+		return fmt.Errorf("function call must include a type if it has a destination")
+	}
+	if ret == nil {
+		return fmt.Errorf("non-void function (type: %s) doesn't return anything", inst.Type)
+	}
+	if !ops.HasType(*ret, *inst.Type) {
+		return fmt.Errorf("type of value returned by function does not match destination type")
+	}
+	if function.Type == nil {
+		return fmt.Errorf("function with void return type used in value call")
+	}
+	if inst.Type.String() != function.Type.String() {
+		return fmt.Errorf("type of value returned by function does not match declaration")
+	}
+	f.env[*inst.Dest] = *ret
+	return nil
+}
+
+func isPhi(inst models.Instruction) bool {
+	return inst.Op != nil && *inst.Op == "phi"
+}
+
+// evalPhis evaluates phis in parallel, every argument is read from the
+// environment as it was before the first of them.
+func evalPhis(phis []models.Instruction, f *frame) error {
+	values := make([]*ops.Value, len(phis))
+	for i, inst := range phis {
+		v, err := evalPhi(inst, f)
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+	for i, inst := range phis {
+		if values[i] == nil {
+			delete(f.env, *inst.Dest)
+		} else {
+			f.env[*inst.Dest] = *values[i]
+		}
+	}
+	return nil
+}
+
+// evalPhi picks the argument associated with the label we just came from. If
+// that label isn't in the phi, or the argument is undefined (the __undefined
+// placeholder that to-ssa emits for example), the destination becomes
+// undefined as well and nil is returned.
+func evalPhi(inst models.Instruction, f *frame) (*ops.Value, error) {
+	if len(inst.Args) != len(inst.Labels) {
+		return nil, fmt.Errorf("phi node has unequal numbers of labels and args")
+	}
+	if inst.Dest == nil {
+		return nil, fmt.Errorf("phi node must have a destination")
+	}
+	if f.lastLabel == nil {
+		return nil, fmt.Errorf("phi node executed with no last label")
+	}
+	for idx, label := range inst.Labels {
+		if label == *f.lastLabel {
+			if v, ok := f.env[inst.Args[idx]]; ok {
+				return &v, nil
+			}
+			break
+		}
+	}
+	return nil, nil
+}
+
+func checkArgs(inst models.Instruction, count int) error {
+	return checkCount(inst, "argument", len(inst.Args), count)
+}
+
+func checkCount(inst models.Instruction, what string, found, count int) error {
+	if found != count {
+		plural := "s"
+		if count == 1 {
+			plural = ""
+		}
+		return fmt.Errorf("%s takes %d %s%s; got %d", *inst.Op, count, what, plural, found)
+	}
+	return nil
+}
+
+func get(env map[string]ops.Value, name string) (ops.Value, error) {
+	v, ok := env[name]
+	if !ok {
+		return ops.Value{}, fmt.Errorf("undefined variable %s", name)
+	}
+	return v, nil
+}
+
+func getTyped(inst models.Instruction, env map[string]ops.Value, idx int, t models.Type) (ops.Value, error) {
+	v, err := get(env, inst.Args[idx])
+	if err != nil {
+		return ops.Value{}, err
+	}
+	if !ops.HasType(v, t) {
+		return ops.Value{}, fmt.Errorf("%s argument %d has wrong type", *inst.Op, idx)
+	}
+	return v, nil
+}
+
+func getPtr(inst models.Instruction, env map[string]ops.Value, idx int) (ops.Pointer, error) {
+	v, err := get(env, inst.Args[idx])
+	if err != nil {
+		return ops.Pointer{}, err
+	}
+	p, ok := v.Pointer()
+	if !ok {
+		return ops.Pointer{}, fmt.Errorf("%s argument %d is not a pointer", *inst.Op, idx)
+	}
+	return p, nil
+}
+
+func set(inst models.Instruction, env map[string]ops.Value, v ops.Value) error {
+	if inst.Dest == nil {
+		return fmt.Errorf("%s must have a destination", *inst.Op)
+	}
+	env[*inst.Dest] = v
+	return nil
+}
@@ -0,0 +1,39 @@
+package interp
+
+import (
+	"fmt"
+	"strconv"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ops"
+)
+
+// parseArg converts a command line argument to @main into a value.
+func parseArg(arg string, t models.Type) (ops.Value, error) {
+	if t.Primitive == nil {
+		return ops.Value{}, fmt.Errorf("main argument of type %s is not supported", t)
+	}
+	switch *t.Primitive {
+	case "int":
+		i, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return ops.Value{}, fmt.Errorf("int argument to main must be an integer; got %s", arg)
+		}
+		return ops.IntValue(i), nil
+	case "float":
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return ops.Value{}, fmt.Errorf("float argument to main must be a number; got %s", arg)
+		}
+		return ops.FloatValue(f), nil
+	case "bool":
+		switch arg {
+		case "true":
+			return ops.BoolValue(true), nil
+		case "false":
+			return ops.BoolValue(false), nil
+		}
+		return ops.Value{}, fmt.Errorf("boolean argument to main must be 'true' or 'false'; got %s", arg)
+	}
+	return ops.Value{}, fmt.Errorf("main argument of type %s is not supported", t)
+}
@@ -0,0 +1,128 @@
+package intervals
+
+import (
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+)
+
+// Bounds at NegInf or PosInf are treated as actual infinities. When finite
+// bounds overflow the program's arithmetic wraps around, so the result could
+// be anything.
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func isInf(n int64) bool {
+	return n == lattice.NegInf || n == lattice.PosInf
+}
+
+func neg(n int64) int64 {
+	switch n {
+	case lattice.NegInf:
+		return lattice.PosInf
+	case lattice.PosInf:
+		return lattice.NegInf
+	}
+	return -n
+}
+
+func inc(n int64) int64 {
+	if isInf(n) || n+1 == lattice.PosInf {
+		return n
+	}
+	return n + 1
+}
+
+func dec(n int64) int64 {
+	if isInf(n) || n-1 == lattice.NegInf {
+		return n
+	}
+	return n - 1
+}
+
+// addBound - ok is false if a and b are finite and their sum overflows
+func addBound(a, b int64) (int64, bool) {
+	switch {
+	case a == lattice.NegInf || b == lattice.NegInf:
+		return lattice.NegInf, true
+	case a == lattice.PosInf || b == lattice.PosInf:
+		return lattice.PosInf, true
+	}
+	s := a + b
+	if (s > a) != (b > 0) || isInf(s) {
+		return 0, false
+	}
+	return s, true
+}
+
+// mulBound - ok is false if a and b are finite and their product overflows
+func mulBound(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	if isInf(a) || isInf(b) {
+		if (a < 0) == (b < 0) {
+			return lattice.PosInf, true
+		}
+		return lattice.NegInf, true
+	}
+	p := a * b
+	if p/b != a || isInf(p) {
+		return 0, false
+	}
+	return p, true
+}
+
+func add(x, y lattice.Interval) lattice.Interval {
+	lo, lok := addBound(x.Lo, y.Lo)
+	hi, hok := addBound(x.Hi, y.Hi)
+	if !lok || !hok {
+		return lattice.Unbounded
+	}
+	return lattice.Interval{Lo: lo, Hi: hi}
+}
+
+func sub(x, y lattice.Interval) lattice.Interval {
+	return add(x, lattice.Interval{Lo: neg(y.Hi), Hi: neg(y.Lo)})
+}
+
+func mul(x, y lattice.Interval) lattice.Interval {
+	out := lattice.Interval{Lo: lattice.PosInf, Hi: lattice.NegInf}
+	for _, a := range []int64{x.Lo, x.Hi} {
+		for _, b := range []int64{y.Lo, y.Hi} {
+			p, ok := mulBound(a, b)
+			if !ok {
+				return lattice.Unbounded
+			}
+			out.Lo = min64(out.Lo, p)
+			out.Hi = max64(out.Hi, p)
+		}
+	}
+	return out
+}
+
+// div only handles finite ranges that don't contain zero, division truncates
+// towards zero so the extremes are still at the corners.
+func div(x, y lattice.Interval) lattice.Interval {
+	if y.Contains(0) || isInf(x.Lo) || isInf(x.Hi) || isInf(y.Lo) || isInf(y.Hi) {
+		return lattice.Unbounded
+	}
+	out := lattice.Interval{Lo: lattice.PosInf, Hi: lattice.NegInf}
+	for _, a := range []int64{x.Lo, x.Hi} {
+		for _, b := range []int64{y.Lo, y.Hi} {
+			out.Lo = min64(out.Lo, a/b)
+			out.Hi = max64(out.Hi, a/b)
+		}
+	}
+	return out
+}
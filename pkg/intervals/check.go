@@ -0,0 +1,151 @@
+package intervals
+
+import (
+	"fmt"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/text"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// Problem is a ptradd whose result can point outside of the allocation its
+// argument points into.
+type Problem struct {
+	Block       string
+	Index       int
+	Instruction models.Instruction
+	// Offset is the range of offsets the result can have from the start
+	// of the allocation and Size is the range of sizes the allocation can
+	// have.
+	Offset lattice.Interval
+	Size   lattice.Interval
+	// Definite is true when every offset is outside every size, otherwise
+	// only some of them are.
+	Definite bool
+}
+
+func (p Problem) String() string {
+	how := "may be"
+	if p.Definite {
+		how = "is"
+	}
+	return fmt.Sprintf("%s:%d: %s: offset %s %s out of bounds for allocation of size %s",
+		p.Block, p.Index, text.Instruction(p.Instruction), p.Offset, how, p.Size)
+}
+
+// Check looks for ptradd instructions in function that can go out of bounds.
+// Pointers whose allocation size isn't known, arguments or the result of a
+// load or call, are never reported.
+func Check(function models.Function) ([]Problem, error) {
+	r, err := Analyze(function, 0)
+	if err != nil {
+		return nil, err
+	}
+	nameToPoints := df.InstructionPoints(r.NameToProgramPoint, df.Forward, r.Transfer)
+	sizes := r.sizes(function, nameToPoints)
+
+	var problems []Problem
+	for _, name := range r.NamesInOrder {
+		for i, point := range nameToPoints[name] {
+			inst := point.Instruction
+			if inst.Op == nil || *inst.Op != "ptradd" || len(inst.Args) != 2 {
+				continue
+			}
+			size, ok := sizes[inst.Args[0]]
+			if !ok {
+				continue
+			}
+			offset, ok := point.Out.Get(*inst.Dest)
+			if !ok {
+				continue
+			}
+			if offset.Lo >= 0 && offset.Hi < size.Lo {
+				continue
+			}
+			problems = append(problems, Problem{
+				Block:       name,
+				Index:       i,
+				Instruction: inst,
+				Offset:      offset,
+				Size:        size,
+				Definite:    offset.Hi < 0 || offset.Lo >= size.Hi,
+			})
+		}
+	}
+	return problems, nil
+}
+
+// sizes finds the range of sizes of the allocation each pointer can point
+// into. This doesn't depend on where in the program the pointer is used, a
+// pointer variable gets every size it's ever given. Pointers that could
+// come from somewhere other than an alloc are left out.
+func (r *Result) sizes(function models.Function, nameToPoints map[string][]df.InstructionPoint[lattice.IntervalLattice]) map[string]lattice.Interval {
+	sizes := make(map[string]lattice.Interval)
+	unknown := utils.NewSet()
+	for _, arg := range function.Args {
+		unknown.Add(arg.Name)
+	}
+
+	give := func(v string, size lattice.Interval) bool {
+		if unknown.Contains(v) {
+			return false
+		}
+		old, ok := sizes[v]
+		if ok {
+			size = old.Meet(size)
+		}
+		sizes[v] = size
+		return !ok || size != old
+	}
+	forget := func(v string) bool {
+		if unknown.Contains(v) {
+			return false
+		}
+		unknown.Add(v)
+		delete(sizes, v)
+		return true
+	}
+
+	changed := true
+	for changed {
+		changed = false
+		for _, name := range r.NamesInOrder {
+			for _, point := range nameToPoints[name] {
+				inst := point.Instruction
+				if inst.Dest == nil || inst.Type == nil || inst.Type.Parameterized == nil {
+					continue
+				}
+				switch *inst.Op {
+				case "alloc":
+					if size, ok := point.In.Get(inst.Args[0]); ok {
+						// Allocating less than one element is an
+						// error, so there's at least one.
+						size.Lo = max64(size.Lo, 1)
+						size.Hi = max64(size.Hi, 1)
+						changed = give(*inst.Dest, size) || changed
+					} else {
+						changed = forget(*inst.Dest) || changed
+					}
+				case "id", "ptradd", "phi":
+					// The offset of a ptradd isn't a pointer
+					args := inst.Args
+					if *inst.Op == "ptradd" {
+						args = args[:1]
+					}
+					for _, arg := range args {
+						if unknown.Contains(arg) {
+							changed = forget(*inst.Dest) || changed
+						} else if size, ok := sizes[arg]; ok {
+							changed = give(*inst.Dest, size) || changed
+						}
+					}
+				default:
+					changed = forget(*inst.Dest) || changed
+				}
+			}
+		}
+	}
+	return sizes
+}
@@ -0,0 +1,260 @@
+// Package intervals finds the range of values each integer variable can hold
+// and uses it to look for pointer arithmetic that leaves its allocation.
+package intervals
+
+import (
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	dfutils "aaronstgeorge.com/self-guided-cs-1620/pkg/df/utils"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ssa"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// How many times the solution is improved after the widened fixpoint is
+// found. Each round can only turn infinite bounds into finite ones.
+const narrowingRounds = 2
+
+// Result is the range of every integer variable at the start and end of each
+// block. Pointers are included too, their range is the offset from the start
+// of the allocation they point into.
+type Result struct {
+	NamesInOrder       []string
+	NameToProgramPoint map[string]*df.ProgramPoint[lattice.IntervalLattice]
+	// Stats is from the widening phase, narrowing takes a fixed number of
+	// passes.
+	Stats df.Stats
+
+	nameToBlock map[string][]models.Instruction
+	cfg         utils.Digraph
+}
+
+// Analyze finds the ranges of the variables in function. Ranges that keep
+// growing around a loop are widened at the loop header and then narrowed
+// back down once a fixpoint is reached. maxIterations is passed on to
+// df.Solve.
+func Analyze(function models.Function, maxIterations int) (*Result, error) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	r := &Result{
+		NamesInOrder: namesInOrder,
+		nameToBlock:  nameToBlock,
+	}
+	if len(namesInOrder) == 0 {
+		r.NameToProgramPoint = make(map[string]*df.ProgramPoint[lattice.IntervalLattice])
+		return r, nil
+	}
+	var err error
+	if r.cfg, err = utils.CFG(namesInOrder, nameToBlock); err != nil {
+		return nil, err
+	}
+
+	// Arguments could be anything
+	entry := lattice.NewIntervalLattice()
+	for _, arg := range function.Args {
+		if isTracked(arg.Type) {
+			entry.Vars[arg.Name] = lattice.Unbounded
+		}
+	}
+
+	r.NameToProgramPoint = dfutils.MakeNameToProgramPoint(nameToBlock, lattice.NewIntervalLattice)
+	r.NameToProgramPoint[namesInOrder[0]].In = entry.Copy()
+
+	stats, err := df.Solve(r.NameToProgramPoint, r.cfg, namesInOrder, df.Forward, r.Transfer, df.Options[lattice.IntervalLattice]{
+		Widen: func(previous, next lattice.IntervalLattice) lattice.IntervalLattice {
+			return previous.Widen(next)
+		},
+		MaxIterations: maxIterations,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.Stats = stats
+
+	for round := 0; round < narrowingRounds; round++ {
+		for i, name := range namesInOrder {
+			preds := utils.Predecessors(r.cfg, name)
+			if i != 0 && len(preds) == 0 {
+				continue
+			}
+			in := lattice.NewIntervalLattice()
+			if i == 0 {
+				in = entry.Copy()
+			}
+			for _, pred := range preds {
+				in = in.Meet(r.NameToProgramPoint[pred].Out)
+			}
+			pp := r.NameToProgramPoint[name]
+			pp.In = in
+			pp.Out = pp.Out.Narrow(r.Transfer(name, pp.Instructions, in))
+		}
+	}
+	return r, nil
+}
+
+// Transfer is the transfer function of the analysis without any widening.
+// When instructions starts with a label the branch that led to the block is
+// taken into account.
+func (r *Result) Transfer(name string, instructions []models.Instruction, in lattice.IntervalLattice) lattice.IntervalLattice {
+	out := in.Copy()
+	if len(instructions) != 0 && instructions[0].Label != nil && name != r.NamesInOrder[0] {
+		r.refine(name, out)
+	}
+	for _, inst := range instructions {
+		if inst.Dest == nil {
+			continue
+		}
+		if i, ok := evaluate(inst, out); ok {
+			out.Vars[*inst.Dest] = i
+		} else {
+			delete(out.Vars, *inst.Dest)
+		}
+	}
+	return out
+}
+
+// isTracked - ints have ranges and pointers have offsets
+func isTracked(t *models.Type) bool {
+	if t == nil {
+		return false
+	}
+	return t.Parameterized != nil || (t.Primitive != nil && *t.Primitive == "int")
+}
+
+// evaluate - the range of the result of inst, ok is false if it isn't
+// tracked or its arguments haven't been reached yet.
+func evaluate(inst models.Instruction, facts lattice.IntervalLattice) (lattice.Interval, bool) {
+	if !isTracked(inst.Type) {
+		return lattice.Interval{}, false
+	}
+
+	var args []lattice.Interval
+	for _, arg := range inst.Args {
+		i, ok := facts.Get(arg)
+		if !ok && *inst.Op != "phi" {
+			return lattice.Interval{}, false
+		}
+		args = append(args, i)
+	}
+
+	switch *inst.Op {
+	case "const":
+		if inst.Value == nil || inst.Value.Float == nil {
+			return lattice.Interval{}, false
+		}
+		return lattice.Point(int64(*inst.Value.Float)), true
+	case "id":
+		return args[0], true
+	case "alloc":
+		return lattice.Point(0), true
+	case "add", "ptradd":
+		return add(args[0], args[1]), true
+	case "sub":
+		return sub(args[0], args[1]), true
+	case "mul":
+		return mul(args[0], args[1]), true
+	case "div":
+		return div(args[0], args[1]), true
+	case "phi":
+		// Only the arguments that have been reached count
+		found := false
+		var out lattice.Interval
+		for i, arg := range inst.Args {
+			in, ok := facts.Get(arg)
+			if arg == ssa.Undefined || !ok {
+				continue
+			}
+			if found {
+				out = out.Meet(in)
+			} else {
+				out = args[i]
+			}
+			found = true
+		}
+		return out, found
+	}
+	// call, load...
+	return lattice.Unbounded, true
+}
+
+// refine narrows the ranges of the arguments of the comparison that decided
+// which way the branch to name went. It only applies when that branch is the
+// only way into the block.
+func (r *Result) refine(name string, facts lattice.IntervalLattice) {
+	preds := utils.Predecessors(r.cfg, name)
+	if len(preds) != 1 {
+		return
+	}
+	block := r.nameToBlock[preds[0]]
+	if len(block) == 0 {
+		return
+	}
+	br := block[len(block)-1]
+	if br.Op == nil || *br.Op != "br" || len(br.Args) != 1 || len(br.Labels) != 2 || br.Labels[0] == br.Labels[1] {
+		return
+	}
+
+	// Find the comparison, its arguments can't change between it and the
+	// branch.
+	idx := -1
+	for i := len(block) - 2; i >= 0; i-- {
+		if block[i].Dest != nil && *block[i].Dest == br.Args[0] {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 || block[idx].Op == nil || len(block[idx].Args) != 2 {
+		return
+	}
+	cmp := block[idx]
+	for _, inst := range block[idx+1 : len(block)-1] {
+		if inst.Dest != nil && (*inst.Dest == cmp.Args[0] || *inst.Dest == cmp.Args[1]) {
+			return
+		}
+	}
+
+	// Everything is rewritten in terms of x < y, x <= y and x == y
+	op, x, y := *cmp.Op, cmp.Args[0], cmp.Args[1]
+	switch op {
+	case "gt":
+		op, x, y = "lt", y, x
+	case "ge":
+		op, x, y = "le", y, x
+	}
+	if br.Labels[1] == name {
+		switch op {
+		case "lt":
+			op, x, y = "le", y, x
+		case "le":
+			op, x, y = "lt", y, x
+		default:
+			return
+		}
+	}
+
+	xi, xok := facts.Get(x)
+	yi, yok := facts.Get(y)
+	if !xok || !yok || x == y {
+		return
+	}
+	switch op {
+	case "lt":
+		xi.Hi = min64(xi.Hi, dec(yi.Hi))
+		yi.Lo = max64(yi.Lo, inc(xi.Lo))
+	case "le":
+		xi.Hi = min64(xi.Hi, yi.Hi)
+		yi.Lo = max64(yi.Lo, xi.Lo)
+	case "eq":
+		xi.Lo = max64(xi.Lo, yi.Lo)
+		xi.Hi = min64(xi.Hi, yi.Hi)
+		yi = xi
+	default:
+		return
+	}
+	// An empty range means the branch can never go this way, there's
+	// nothing useful to say about that here.
+	if xi.Lo > xi.Hi || yi.Lo > yi.Hi {
+		return
+	}
+	facts.Vars[x] = xi
+	facts.Vars[y] = yi
+}
@@ -0,0 +1,35 @@
+package lattice
+
+import "aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+
+// The bit set lattices are the same as the set lattices but much faster when
+// there are a lot of names. Every value in an analysis has to come from the
+// same utils.Universe.
+
+var _ Lattice[UnionMeetBitSetLattice] = UnionMeetBitSetLattice{}
+
+type UnionMeetBitSetLattice struct {
+	utils.BitSet
+}
+
+func (s UnionMeetBitSetLattice) Meet(l UnionMeetBitSetLattice) UnionMeetBitSetLattice {
+	return UnionMeetBitSetLattice{s.BitSet.Union(l.BitSet)}
+}
+
+func (s UnionMeetBitSetLattice) Equal(l UnionMeetBitSetLattice) bool {
+	return s.BitSet.Equal(l.BitSet)
+}
+
+var _ Lattice[IntersectMeetBitSetLattice] = IntersectMeetBitSetLattice{}
+
+type IntersectMeetBitSetLattice struct {
+	utils.BitSet
+}
+
+func (s IntersectMeetBitSetLattice) Meet(l IntersectMeetBitSetLattice) IntersectMeetBitSetLattice {
+	return IntersectMeetBitSetLattice{s.BitSet.Intersect(l.BitSet)}
+}
+
+func (s IntersectMeetBitSetLattice) Equal(l IntersectMeetBitSetLattice) bool {
+	return s.BitSet.Equal(l.BitSet)
+}
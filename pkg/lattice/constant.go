@@ -0,0 +1,126 @@
+package lattice
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ops"
+)
+
+type ConstantState int
+
+const (
+	// Top - nothing is known about the variable yet
+	Top ConstantState = iota
+	// Known - the variable holds Value on every path
+	Known
+	// Bottom - the variable can hold more than one value
+	Bottom
+)
+
+// Constant is what is known about the value of a single variable
+type Constant struct {
+	State ConstantState
+	Value ops.Value
+}
+
+func (c Constant) String() string {
+	switch c.State {
+	case Top:
+		return "⊤"
+	case Known:
+		return c.Value.String()
+	}
+	return "⊥"
+}
+
+func (c Constant) Meet(l Constant) Constant {
+	switch {
+	case c.State == Top:
+		return l
+	case l.State == Top:
+		return c
+	case c.State == Bottom || l.State == Bottom:
+		return Constant{State: Bottom}
+	case !c.Equal(l):
+		return Constant{State: Bottom}
+	}
+	return c
+}
+
+// Equal - same state and, when known, the same value by Value.Equal
+func (c Constant) Equal(l Constant) bool {
+	return c.State == l.State && c.Value.Equal(l.Value)
+}
+
+var _ Lattice[ConstantLattice] = ConstantLattice{}
+
+// ConstantLattice maps variables to what is known about their value.
+// Variables that are missing from the map are ⊤, so the zero value is the
+// top of the lattice.
+type ConstantLattice struct {
+	Vars map[string]Constant
+}
+
+func NewConstantLattice() ConstantLattice {
+	return ConstantLattice{Vars: make(map[string]Constant)}
+}
+
+func (c ConstantLattice) Get(v string) Constant {
+	if constant, ok := c.Vars[v]; ok {
+		return constant
+	}
+	return Constant{State: Top}
+}
+
+// Copy - a ConstantLattice that can be changed without changing c
+func (c ConstantLattice) Copy() ConstantLattice {
+	out := NewConstantLattice()
+	for v, constant := range c.Vars {
+		out.Vars[v] = constant
+	}
+	return out
+}
+
+func (c ConstantLattice) Meet(l ConstantLattice) ConstantLattice {
+	out := c.Copy()
+	for v, constant := range l.Vars {
+		out.Vars[v] = out.Get(v).Meet(constant)
+	}
+	return out
+}
+
+// Equal - variables missing from one side are equal to ⊤ on the other
+func (c ConstantLattice) Equal(l ConstantLattice) bool {
+	for v, constant := range c.Vars {
+		if !l.Get(v).Equal(constant) {
+			return false
+		}
+	}
+	for v, constant := range l.Vars {
+		if !c.Get(v).Equal(constant) {
+			return false
+		}
+	}
+	return true
+}
+
+// String lists every variable that isn't ⊤ in sorted order
+func (c ConstantLattice) String() string {
+	var vars []string
+	for v, constant := range c.Vars {
+		if constant.State != Top {
+			vars = append(vars, v)
+		}
+	}
+	if len(vars) == 0 {
+		return "∅"
+	}
+	sort.Strings(vars)
+	parts := make([]string, len(vars))
+	for i, v := range vars {
+		parts[i] = fmt.Sprintf("%s: %s", v, c.Vars[v])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,172 @@
+package lattice
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// The ends of the number line, an interval bound at one of these is
+// unbounded in that direction.
+const (
+	NegInf int64 = math.MinInt64
+	PosInf int64 = math.MaxInt64
+)
+
+// Interval is the range of integers [Lo, Hi], Lo is never greater than Hi.
+type Interval struct {
+	Lo int64
+	Hi int64
+}
+
+// Unbounded is the interval containing every integer
+var Unbounded = Interval{Lo: NegInf, Hi: PosInf}
+
+func Point(n int64) Interval {
+	return Interval{Lo: n, Hi: n}
+}
+
+func (i Interval) Contains(n int64) bool {
+	return i.Lo <= n && n <= i.Hi
+}
+
+func bound(n int64) string {
+	switch n {
+	case NegInf:
+		return "-∞"
+	case PosInf:
+		return "+∞"
+	}
+	return fmt.Sprint(n)
+}
+
+func (i Interval) String() string {
+	return fmt.Sprintf("[%s, %s]", bound(i.Lo), bound(i.Hi))
+}
+
+// Meet is the smallest interval containing both intervals
+func (i Interval) Meet(l Interval) Interval {
+	if l.Lo < i.Lo {
+		i.Lo = l.Lo
+	}
+	if l.Hi > i.Hi {
+		i.Hi = l.Hi
+	}
+	return i
+}
+
+// Widen jumps any bound that moved outward straight to infinity. Intervals
+// can grow forever around a loop, after widening each bound can only move
+// once.
+func (i Interval) Widen(next Interval) Interval {
+	if next.Lo < i.Lo {
+		i.Lo = NegInf
+	}
+	if next.Hi > i.Hi {
+		i.Hi = PosInf
+	}
+	return i
+}
+
+// Narrow takes back the infinite bounds introduced by widening when next has
+// found a finite one.
+func (i Interval) Narrow(next Interval) Interval {
+	if i.Lo == NegInf {
+		i.Lo = next.Lo
+	}
+	if i.Hi == PosInf {
+		i.Hi = next.Hi
+	}
+	return i
+}
+
+var _ Lattice[IntervalLattice] = IntervalLattice{}
+
+// IntervalLattice maps variables to the range of values they can hold.
+// Variables that are missing from the map haven't been given a value yet,
+// so like ConstantLattice the zero value is the top of the lattice.
+type IntervalLattice struct {
+	Vars map[string]Interval
+}
+
+func NewIntervalLattice() IntervalLattice {
+	return IntervalLattice{Vars: make(map[string]Interval)}
+}
+
+func (c IntervalLattice) Get(v string) (Interval, bool) {
+	i, ok := c.Vars[v]
+	return i, ok
+}
+
+// Copy - an IntervalLattice that can be changed without changing c
+func (c IntervalLattice) Copy() IntervalLattice {
+	out := NewIntervalLattice()
+	for v, i := range c.Vars {
+		out.Vars[v] = i
+	}
+	return out
+}
+
+func (c IntervalLattice) Meet(l IntervalLattice) IntervalLattice {
+	out := c.Copy()
+	for v, i := range l.Vars {
+		if mine, ok := out.Vars[v]; ok {
+			i = mine.Meet(i)
+		}
+		out.Vars[v] = i
+	}
+	return out
+}
+
+// Widen widens each variable, next should have come from a later iteration
+// than c.
+func (c IntervalLattice) Widen(next IntervalLattice) IntervalLattice {
+	out := next.Copy()
+	for v, i := range next.Vars {
+		if mine, ok := c.Vars[v]; ok {
+			out.Vars[v] = mine.Widen(i)
+		}
+	}
+	return out
+}
+
+// Narrow narrows each variable, next should have come from a later iteration
+// than c.
+func (c IntervalLattice) Narrow(next IntervalLattice) IntervalLattice {
+	out := c.Copy()
+	for v, i := range next.Vars {
+		if mine, ok := c.Vars[v]; ok {
+			out.Vars[v] = mine.Narrow(i)
+		}
+	}
+	return out
+}
+
+func (c IntervalLattice) Equal(l IntervalLattice) bool {
+	if len(c.Vars) != len(l.Vars) {
+		return false
+	}
+	for v, i := range c.Vars {
+		if li, ok := l.Vars[v]; !ok || li != i {
+			return false
+		}
+	}
+	return true
+}
+
+func (c IntervalLattice) String() string {
+	if len(c.Vars) == 0 {
+		return "∅"
+	}
+	var vars []string
+	for v := range c.Vars {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+	parts := make([]string, len(vars))
+	for i, v := range vars {
+		parts[i] = fmt.Sprintf("%s: %s", v, c.Vars[v])
+	}
+	return strings.Join(parts, ", ")
+}
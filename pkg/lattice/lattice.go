@@ -7,9 +7,10 @@ import (
 )
 
 type Lattice[T any] interface {
-	// Stringer is used for change comparison in the transfer functions
 	fmt.Stringer
 	Meet(l T) T
+	// Equal is how the solver tells that a value has stopped changing
+	Equal(l T) bool
 }
 
 var _ Lattice[UnionMeetSetLattice] = UnionMeetSetLattice{}
@@ -22,6 +23,10 @@ func (s UnionMeetSetLattice) Meet(l UnionMeetSetLattice) UnionMeetSetLattice {
 	return UnionMeetSetLattice{utils.Union(s.Set, l.Set)}
 }
 
+func (s UnionMeetSetLattice) Equal(l UnionMeetSetLattice) bool {
+	return s.Set.Equal(l.Set)
+}
+
 var _ Lattice[IntersetMeetSetLattice] = IntersetMeetSetLattice{}
 
 type IntersetMeetSetLattice struct {
@@ -31,3 +36,7 @@ type IntersetMeetSetLattice struct {
 func (s IntersetMeetSetLattice) Meet(l IntersetMeetSetLattice) IntersetMeetSetLattice {
 	return IntersetMeetSetLattice{utils.Intersect(s.Set, l.Set)}
 }
+
+func (s IntersetMeetSetLattice) Equal(l IntersetMeetSetLattice) bool {
+	return s.Set.Equal(l.Set)
+}
@@ -0,0 +1,372 @@
+// Package licm implements loop invariant code motion.
+package licm
+
+import (
+	"fmt"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/dominators"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/liveness"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/loops"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// Operations that can be moved, they have no side effects and can't fail.
+// Division is missing on purpose, hoisting a division by zero out of a loop
+// could make a program fail before it prints what it would have otherwise.
+var movable = utils.NewSet(
+	"const", "id", "add", "sub", "mul",
+	"eq", "lt", "gt", "le", "ge",
+	"not", "and", "or",
+	"fadd", "fsub", "fmul", "fdiv",
+	"feq", "flt", "fgt", "fle", "fge",
+	"ptradd",
+)
+
+type analysis struct {
+	namesInOrder     []string
+	nameToBlock      map[string][]models.Instruction
+	cfg              utils.Digraph
+	nameToDominators map[string]utils.Set
+	forest           []*loops.Loop
+	live             map[string]*df.ProgramPoint[lattice.UnionMeetBitSetLattice]
+}
+
+func analyze(function models.Function) (analysis, error) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		return analysis{}, err
+	}
+	live, err := liveness.Live(function, namesInOrder, nameToBlock, cfg)
+	if err != nil {
+		return analysis{}, err
+	}
+	return newAnalysis(namesInOrder, nameToBlock, cfg, dominators.Dominators(namesInOrder, cfg), live), nil
+}
+
+func newAnalysis(namesInOrder []string,
+	nameToBlock map[string][]models.Instruction,
+	cfg utils.Digraph,
+	nameToDominators map[string]utils.Set,
+	live map[string]*df.ProgramPoint[lattice.UnionMeetBitSetLattice]) analysis {
+
+	return analysis{
+		namesInOrder:     namesInOrder,
+		nameToBlock:      nameToBlock,
+		cfg:              cfg,
+		nameToDominators: nameToDominators,
+		forest:           loops.Forest(namesInOrder, cfg, nameToDominators),
+		live:             live,
+	}
+}
+
+// LICM moves loop invariant computations in function out of their loops and
+// into the loop's preheader, adding a preheader if there isn't one. Inner
+// loops are done first so that what is hoisted out of them can keep moving
+// out of the loops they are nested in.
+func LICM(function models.Function) (models.Function, error) {
+	if len(function.Instrs) == 0 {
+		return function, nil
+	}
+	a, err := analyze(function)
+	if err != nil {
+		return function, err
+	}
+	return hoistLoops(function, a)
+}
+
+// Hoist is LICM for when the blocks of function, its control flow graph,
+// dominators and live variables are already known.
+func Hoist(function models.Function,
+	namesInOrder []string,
+	nameToBlock map[string][]models.Instruction,
+	cfg utils.Digraph,
+	nameToDominators map[string]utils.Set,
+	live map[string]*df.ProgramPoint[lattice.UnionMeetBitSetLattice]) (models.Function, error) {
+
+	if len(namesInOrder) == 0 {
+		return function, nil
+	}
+	return hoistLoops(function, newAnalysis(namesInOrder, nameToBlock, cfg, nameToDominators, live))
+}
+
+func hoistLoops(function models.Function, a analysis) (models.Function, error) {
+	var headers []string
+	var postOrder func(ls []*loops.Loop)
+	postOrder = func(ls []*loops.Loop) {
+		for _, l := range ls {
+			postOrder(l.Children)
+			headers = append(headers, l.Header)
+		}
+	}
+	postOrder(a.forest)
+
+	// Moving instructions or adding a preheader changes the function so
+	// everything is recomputed for the next loop.
+	for _, header := range headers {
+		moved := false
+		var err error
+		loops.Walk(a.forest, func(l *loops.Loop) {
+			if l.Header == header {
+				function.Instrs, moved = a.hoist(function, l)
+			}
+		})
+		if moved {
+			if a, err = analyze(function); err != nil {
+				return function, err
+			}
+		}
+	}
+	return function, nil
+}
+
+func (a analysis) dominates(def, use utils.Site) bool {
+	if def.Block == use.Block {
+		return def.Index < use.Index
+	}
+	return a.nameToDominators[use.Block].Contains(def.Block)
+}
+
+// hoist moves the invariant instructions of l to its preheader, returning the
+// instructions of function and whether any moved
+func (a analysis) hoist(function models.Function, l *loops.Loop) ([]models.Instruction, bool) {
+	var body []string
+	for _, name := range a.namesInOrder {
+		if l.Body.Contains(name) {
+			body = append(body, name)
+		}
+	}
+
+	defs := make(map[string][]utils.Site)
+	uses := make(map[string][]utils.Site)
+	for _, name := range body {
+		for i, inst := range a.nameToBlock[name] {
+			if inst.Dest != nil {
+				defs[*inst.Dest] = append(defs[*inst.Dest], utils.Site{Block: name, Index: i})
+			}
+			for _, arg := range inst.Args {
+				uses[arg] = append(uses[arg], utils.Site{Block: name, Index: i})
+			}
+		}
+	}
+
+	live := a.live
+
+	// An argument is invariant if it's never defined in the loop or if
+	// its only definition in the loop is invariant and always happens
+	// before the use. Keep marking until nothing changes.
+	invariant := make(map[utils.Site]bool)
+	isInvariantArg := func(arg string, use utils.Site) bool {
+		ds := defs[arg]
+		return len(ds) == 0 || (len(ds) == 1 && invariant[ds[0]] && a.dominates(ds[0], use))
+	}
+	changed := true
+	for changed {
+		changed = false
+		for _, name := range body {
+			for i, inst := range a.nameToBlock[name] {
+				s := utils.Site{Block: name, Index: i}
+				if invariant[s] || inst.Dest == nil || inst.Op == nil || !movable.Contains(*inst.Op) {
+					continue
+				}
+				all := true
+				for _, arg := range inst.Args {
+					all = all && isInvariantArg(arg, s)
+				}
+				if all {
+					invariant[s] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	// Now figure out which invariant instructions are safe to move. Going
+	// in order means the definitions of arguments are decided first.
+	hoisted := make(map[utils.Site]bool)
+	var moved []models.Instruction
+	for _, name := range body {
+		for i, inst := range a.nameToBlock[name] {
+			s := utils.Site{Block: name, Index: i}
+			if !invariant[s] || len(defs[*inst.Dest]) != 1 {
+				continue
+			}
+			// Executing this when the loop wouldn't have is fine
+			// if it either would have been executed anyway, or
+			// nobody after the loop can tell.
+			dominatesExits, deadAfter := true, true
+			for _, e := range l.Exits {
+				dominatesExits = dominatesExits && a.nameToDominators[e.From].Contains(name)
+				deadAfter = deadAfter && !live[e.To].In.Contains(*inst.Dest)
+			}
+			safe := dominatesExits || deadAfter
+			// Every use in the loop has to see this definition,
+			// otherwise a value from before the loop is used on
+			// the first iteration.
+			for _, use := range uses[*inst.Dest] {
+				safe = safe && a.dominates(s, use)
+			}
+			for _, arg := range inst.Args {
+				if ds := defs[arg]; len(ds) == 1 {
+					safe = safe && hoisted[ds[0]]
+				}
+			}
+			if safe {
+				hoisted[s] = true
+				moved = append(moved, inst)
+			}
+		}
+	}
+
+	if len(moved) == 0 {
+		return function.Instrs, false
+	}
+
+	nameToBlock := make(map[string][]models.Instruction)
+	for _, name := range a.namesInOrder {
+		var block []models.Instruction
+		for i, inst := range a.nameToBlock[name] {
+			if !hoisted[utils.Site{Block: name, Index: i}] {
+				block = append(block, inst)
+			}
+		}
+		nameToBlock[name] = block
+	}
+
+	namesInOrder := a.namesInOrder
+	if l.Preheader != "" {
+		nameToBlock[l.Preheader] = utils.InsertBeforeTerminator(nameToBlock[l.Preheader], moved)
+	} else {
+		namesInOrder = a.addPreheader(function, l, nameToBlock, moved)
+	}
+	return utils.FlattenBlocks(namesInOrder, nameToBlock), true
+}
+
+// addPreheader puts a new block containing insts right in front of the loop
+// header. Edges into the header from outside the loop are redirected to it.
+// Because it's placed directly before the header anything that fell through
+// into the header now falls through into the preheader instead, so latches
+// that did that get an explicit jump.
+func (a analysis) addPreheader(function models.Function, l *loops.Loop, nameToBlock map[string][]models.Instruction, insts []models.Instruction) []string {
+	used := utils.NewSet()
+	for _, inst := range function.Instrs {
+		if inst.Label != nil {
+			used.Add(*inst.Label)
+		}
+	}
+	used.Add(a.namesInOrder...)
+	preheader := l.Header + ".preheader"
+	for i := 1; used.Contains(preheader); i++ {
+		preheader = fmt.Sprintf("%s.preheader.%d", l.Header, i)
+	}
+
+	// The header needs a label to be jumped to
+	header := nameToBlock[l.Header]
+	if len(header) == 0 || header[0].Label == nil {
+		label := l.Header
+		header = append([]models.Instruction{{Label: &label}}, header...)
+		nameToBlock[l.Header] = header
+	}
+
+	jmp := "jmp"
+	for _, pred := range utils.Predecessors(a.cfg, l.Header) {
+		block := nameToBlock[pred]
+		last := models.Instruction{}
+		if len(block) != 0 {
+			last = block[len(block)-1]
+		}
+		isJump := last.Op != nil && (*last.Op == "jmp" || *last.Op == "br")
+		switch {
+		case l.Body.Contains(pred) && !isJump:
+			nameToBlock[pred] = append(block, models.Instruction{Op: &jmp, Labels: []string{l.Header}})
+		case !l.Body.Contains(pred) && isJump:
+			labels := make([]string, len(last.Labels))
+			for i, label := range last.Labels {
+				if label == l.Header {
+					label = preheader
+				}
+				labels[i] = label
+			}
+			last.Labels = labels
+			block[len(block)-1] = last
+		}
+	}
+
+	label := preheader
+	block := []models.Instruction{{Label: &label}}
+	block = append(block, a.splitPhis(function, l, nameToBlock, preheader)...)
+	block = append(block, insts...)
+	block = append(block, models.Instruction{Op: &jmp, Labels: []string{l.Header}})
+	nameToBlock[preheader] = block
+
+	var namesInOrder []string
+	for _, name := range a.namesInOrder {
+		if name == l.Header {
+			namesInOrder = append(namesInOrder, preheader)
+		}
+		namesInOrder = append(namesInOrder, name)
+	}
+	return namesInOrder
+}
+
+// splitPhis moves the arguments of the header's phis that come from outside
+// the loop to preheader. A phi with one of them just has its label changed,
+// one with more gets a phi in the preheader to merge them first, which is
+// returned.
+func (a analysis) splitPhis(function models.Function, l *loops.Loop, nameToBlock map[string][]models.Instruction, preheader string) []models.Instruction {
+	used := utils.NewSet()
+	for _, inst := range function.Instrs {
+		if inst.Dest != nil {
+			used.Add(*inst.Dest)
+		}
+	}
+
+	var phis []models.Instruction
+	header := nameToBlock[l.Header]
+	for i, inst := range header {
+		if inst.Op == nil || *inst.Op != "phi" {
+			continue
+		}
+		// The preheader takes the place of the first argument from outside
+		var args, labels, outsideArgs, outsideLabels []string
+		at := -1
+		for j, label := range inst.Labels {
+			if j >= len(inst.Args) {
+				break
+			}
+			if l.Body.Contains(label) {
+				args = append(args, inst.Args[j])
+				labels = append(labels, label)
+				continue
+			}
+			if at < 0 {
+				at = len(args)
+				args = append(args, "")
+				labels = append(labels, preheader)
+			}
+			outsideArgs = append(outsideArgs, inst.Args[j])
+			outsideLabels = append(outsideLabels, label)
+		}
+		switch len(outsideArgs) {
+		case 0:
+			continue
+		case 1:
+			args[at] = outsideArgs[0]
+		default:
+			dest := *inst.Dest + ".pre"
+			for k := 1; used.Contains(dest); k++ {
+				dest = fmt.Sprintf("%s.pre.%d", *inst.Dest, k)
+			}
+			used.Add(dest)
+			op := "phi"
+			phis = append(phis, models.Instruction{Dest: &dest, Op: &op, Type: inst.Type, Args: outsideArgs, Labels: outsideLabels})
+			args[at] = dest
+		}
+		inst.Args, inst.Labels = args, labels
+		header[i] = inst
+	}
+	return phis
+}
@@ -0,0 +1,55 @@
+// Package liveness finds the variables that are live, that might still be
+// read, at the start and end of every block.
+package liveness
+
+import (
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	dfutils "aaronstgeorge.com/self-guided-cs-1620/pkg/df/utils"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// Variables - every variable in function, arguments included
+func Variables(function models.Function) *utils.Universe {
+	var names []string
+	for _, arg := range function.Args {
+		names = append(names, arg.Name)
+	}
+	for _, inst := range function.Instrs {
+		if inst.Dest != nil {
+			names = append(names, *inst.Dest)
+		}
+		names = append(names, inst.Args...)
+	}
+	return utils.NewUniverse(names...)
+}
+
+// Transfer is the transfer function for liveness, which goes backwards. What's
+// live before the instructions is what's live after them less what they
+// assign, plus what they read.
+func Transfer(_ string, instructions []models.Instruction, out lattice.UnionMeetBitSetLattice) lattice.UnionMeetBitSetLattice {
+	live := out.Copy()
+	for i := len(instructions) - 1; i >= 0; i-- {
+		if instructions[i].Dest != nil {
+			live.Remove(*instructions[i].Dest)
+		}
+		live.Add(instructions[i].Args...)
+	}
+	return lattice.UnionMeetBitSetLattice{BitSet: live}
+}
+
+// Live - the variables live at the start and end of each block of function
+func Live(function models.Function, namesInOrder []string, nameToBlock map[string][]models.Instruction, cfg utils.Digraph) (map[string]*df.ProgramPoint[lattice.UnionMeetBitSetLattice], error) {
+	u := Variables(function)
+	nameToProgramPoint := dfutils.MakeNameToProgramPoint(nameToBlock, func() lattice.UnionMeetBitSetLattice {
+		return lattice.UnionMeetBitSetLattice{BitSet: u.Empty()}
+	})
+	if len(namesInOrder) == 0 {
+		return nameToProgramPoint, nil
+	}
+	if _, err := df.DF(nameToProgramPoint, cfg, namesInOrder, df.Reverse, Transfer); err != nil {
+		return nil, err
+	}
+	return nameToProgramPoint, nil
+}
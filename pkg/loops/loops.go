@@ -0,0 +1,192 @@
+// Package loops finds the natural loops in a control flow graph and arranges
+// them into a forest by nesting.
+package loops
+
+import (
+	"sort"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+type Edge struct {
+	From string
+	To   string
+}
+
+type Loop struct {
+	Header string
+	// Body includes the header and the bodies of any nested loops
+	Body utils.Set
+	// Latches are the sources of the back edges to the header
+	Latches []string
+	// Exits are the edges that leave the loop
+	Exits []Edge
+	// Preheader is the only block outside the loop that jumps to the
+	// header, provided that it has no other successors. It's empty when
+	// there is no such block.
+	Preheader string
+
+	Parent   *Loop
+	Children []*Loop
+}
+
+// Depth - how many loops this loop is nested in, outer most loops have a depth
+// of 1.
+func (l *Loop) Depth() int {
+	depth := 0
+	for ; l != nil; l = l.Parent {
+		depth++
+	}
+	return depth
+}
+
+// Walk visits every loop in the forest, parents before children.
+func Walk(forest []*Loop, visit func(l *Loop)) {
+	for _, l := range forest {
+		visit(l)
+		Walk(l.Children, visit)
+	}
+}
+
+// BackEdges finds the edges in the control flow graph whose destination
+// dominates their source. Blocks that can't be reached from the entry are
+// ignored, everything "dominates" them.
+func BackEdges(namesInOrder []string, cfg utils.Digraph, nameToDominators map[string]utils.Set) []Edge {
+	if len(namesInOrder) == 0 {
+		return nil
+	}
+	reachable := Reachable(namesInOrder[0], cfg)
+
+	var edges []Edge
+	for _, name := range namesInOrder {
+		if !reachable.Contains(name) {
+			continue
+		}
+		for _, succ := range utils.Successors(cfg, name) {
+			if nameToDominators[name].Contains(succ) {
+				edges = append(edges, Edge{From: name, To: succ})
+			}
+		}
+	}
+	return edges
+}
+
+// Reachable - every block reachable from start, including start
+func Reachable(start string, cfg utils.Digraph) utils.Set {
+	reachable := utils.NewSet(start)
+	workList := []string{start}
+	for len(workList) != 0 {
+		name := workList[len(workList)-1]
+		workList = workList[:len(workList)-1]
+		for _, succ := range utils.Successors(cfg, name) {
+			if !reachable.Contains(succ) {
+				reachable.Add(succ)
+				workList = append(workList, succ)
+			}
+		}
+	}
+	return reachable
+}
+
+// Forest finds the natural loops of the control flow graph. Back edges to the
+// same header are combined into a single loop. The returned loops are the
+// outermost ones, nested loops are reachable through Children. Loops are
+// ordered by the position of their header in namesInOrder.
+func Forest(namesInOrder []string, cfg utils.Digraph, nameToDominators map[string]utils.Set) []*Loop {
+	if len(namesInOrder) == 0 {
+		return nil
+	}
+	reachable := Reachable(namesInOrder[0], cfg)
+
+	headerToLoop := make(map[string]*Loop)
+	var loops []*Loop
+	for _, e := range BackEdges(namesInOrder, cfg, nameToDominators) {
+		l, ok := headerToLoop[e.To]
+		if !ok {
+			l = &Loop{Header: e.To, Body: utils.NewSet(e.To)}
+			headerToLoop[e.To] = l
+			loops = append(loops, l)
+		}
+		l.Latches = append(l.Latches, e.From)
+		addNaturalLoop(l.Body, e, cfg, reachable)
+	}
+
+	order := make(map[string]int)
+	for i, name := range namesInOrder {
+		order[name] = i
+	}
+
+	for _, l := range loops {
+		sort.Slice(l.Latches, func(i, j int) bool { return order[l.Latches[i]] < order[l.Latches[j]] })
+		for _, name := range namesInOrder {
+			if !l.Body.Contains(name) {
+				continue
+			}
+			for _, succ := range utils.Successors(cfg, name) {
+				if !l.Body.Contains(succ) {
+					l.Exits = append(l.Exits, Edge{From: name, To: succ})
+				}
+			}
+		}
+		l.Preheader = preheader(l, namesInOrder, cfg)
+	}
+
+	// The parent of a loop is the smallest other loop containing its
+	// header. Going from largest to smallest means parents are always
+	// settled before their children.
+	sort.SliceStable(loops, func(i, j int) bool { return len(loops[i].Body) > len(loops[j].Body) })
+	var forest []*Loop
+	for i, l := range loops {
+		for j := i - 1; j >= 0; j-- {
+			if loops[j].Body.Contains(l.Header) {
+				l.Parent = loops[j]
+				break
+			}
+		}
+		if l.Parent == nil {
+			forest = append(forest, l)
+		} else {
+			l.Parent.Children = append(l.Parent.Children, l)
+		}
+	}
+
+	byHeader := func(ls []*Loop) {
+		sort.Slice(ls, func(i, j int) bool { return order[ls[i].Header] < order[ls[j].Header] })
+	}
+	byHeader(forest)
+	Walk(forest, func(l *Loop) { byHeader(l.Children) })
+	return forest
+}
+
+// addNaturalLoop adds every block that can reach the source of the back edge
+// without going through the header.
+func addNaturalLoop(body utils.Set, e Edge, cfg utils.Digraph, reachable utils.Set) {
+	workList := []string{e.From}
+	for len(workList) != 0 {
+		name := workList[len(workList)-1]
+		workList = workList[:len(workList)-1]
+		if body.Contains(name) || !reachable.Contains(name) {
+			continue
+		}
+		body.Add(name)
+		workList = append(workList, utils.Predecessors(cfg, name)...)
+	}
+}
+
+func preheader(l *Loop, namesInOrder []string, cfg utils.Digraph) string {
+	// The entry block is entered from outside the function as well, no
+	// block in the function can be its preheader.
+	if len(namesInOrder) == 0 || namesInOrder[0] == l.Header {
+		return ""
+	}
+	var outside []string
+	for _, pred := range utils.Predecessors(cfg, l.Header) {
+		if !l.Body.Contains(pred) {
+			outside = append(outside, pred)
+		}
+	}
+	if len(outside) != 1 || len(utils.Successors(cfg, outside[0])) != 1 {
+		return ""
+	}
+	return outside[0]
+}
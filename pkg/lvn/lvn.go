@@ -0,0 +1,258 @@
+// Package lvn has local value numbering. The code could use some work here,
+// but I need to move on.
+package lvn
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+func equalComputedValue(a, b models.Instruction) bool {
+	// Blind pointer dereference is safe here because an instruction
+	// wouldn't have a destination if it didn't have an operation. Getting
+	// here in the code if we didn't have a destination should be
+	// impossible.
+	if *a.Op != *b.Op {
+		return false
+	}
+	if (a.Value == nil) != (b.Value == nil) {
+		return false
+	}
+	if a.Value != nil {
+		if (a.Value.Float == nil) != (b.Value.Float == nil) {
+			return false
+		}
+		if a.Value.Float != nil {
+			if *a.Value.Float != *b.Value.Float {
+				return false
+			}
+		}
+		if (a.Value.Bool == nil) != (b.Value.Bool == nil) {
+			return false
+		}
+		if a.Value.Bool != nil {
+			if *a.Value.Bool != *b.Value.Bool {
+				return false
+			}
+		}
+	}
+	if len(a.Args) != len(b.Args) {
+		return false
+	}
+	for i := range a.Args {
+		if a.Args[i] != b.Args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equivalentComputationIndex(table []lvnTableEntry, instruction models.Instruction, prop bool) (int, bool, error) {
+	// If we are doing propagation "see through" the id instruction and just
+	// return the computation it points at. One case that makes this
+	// difficult: if the computation the variable points at happened outside
+	// this block then we don't want to see through this id instruction
+	// since it is a local optimization.
+	if prop && *instruction.Op == "id" {
+		i, err := strconv.Atoi(instruction.Args[0])
+		if err != nil {
+			return -1, false, err
+		}
+		entry := table[i]
+		// This will be nill only if the variable refers to something
+		// that was computed outside of this block.
+		if entry.inst != nil {
+			return i, true, nil
+		}
+	}
+	// Every one of these makes something new, or reads memory that might
+	// have changed, so two of them with the same arguments aren't the same
+	// value
+	if unique.Contains(*instruction.Op) {
+		return -1, false, nil
+	}
+	for i, entry := range table {
+		if entry.inst != nil {
+			if equalComputedValue(instruction, *entry.inst) {
+				return i, true, nil
+			}
+		}
+	}
+	return -1, false, nil
+}
+
+var unique = utils.NewSet("call", "alloc", "load")
+
+type lvnTableEntry struct {
+	inst *models.Instruction
+	cv   string // canonical value
+}
+
+var id = "id"
+
+// lvn modifies block in place, but not the arguments of its instructions
+func lvn(block []models.Instruction, prop bool) error {
+	// This stores a mapping between a computation that has taken place and
+	// a variable with which it can be referred. Unlike the environment this
+	// is a static map it doesn't update, new things are simply added. So a
+	// variable in the table must always be able to refer to the computation
+	// regardless of what the environment currently contains.
+	var table []lvnTableEntry
+	// This is our environment. It stores mappings between live variables
+	// currently in our program and computations that have taken place. As
+	// our program overwrites things variables in our environment will
+	// change, while the computations don't.
+	varToTableIdx := make(map[string]int)
+
+	for blockIdx, inst := range block {
+		inst.Args = append([]string(nil), inst.Args...)
+		if inst.Dest != nil {
+			var argTableIdxs []int
+			var mangledArgs []string
+			for _, arg := range inst.Args {
+				// If we don't have this in our environment it
+				// came from a global context. Simple thing to
+				// do here is just drop it in the environment
+				// and table with no computation. It has a
+				// computation we just don't know what it is.
+				if _, ok := varToTableIdx[arg]; !ok {
+					tableEntry := lvnTableEntry{
+						cv: arg,
+					}
+					table = append(table, tableEntry)
+					varToTableIdx[arg] = len(table) - 1
+				}
+				argTableIdxs = append(argTableIdxs, varToTableIdx[arg])
+				mangledArgs = append(mangledArgs, strconv.Itoa(varToTableIdx[arg]))
+				// For commutative operations consider sorted to
+				// be canonical
+				if *inst.Op == "add" || *inst.Op == "mul" {
+					sort.Strings(mangledArgs)
+				}
+			}
+			// We are reusing models.Instruction for our table value
+			// even though it could probably be better expressed in
+			// it's own type. Hence the mangled args.
+			tableInst := inst
+			tableInst.Args = mangledArgs
+			tableIdx, ok, err := equivalentComputationIndex(table, tableInst, prop)
+			if err != nil {
+				return err
+			}
+			if ok {
+				foundInst := table[tableIdx]
+				// equivalentComputationIndex will never return
+				// table entry without operation blind
+				// dereference is safe here
+				if prop && *foundInst.inst.Op == "const" {
+					// Propagate constants. Anything else
+					// could read a variable that has been
+					// clobbered since, so it's copied
+					temp := *foundInst.inst
+					temp.Dest = inst.Dest
+					inst = temp
+				} else {
+					inst = models.Instruction{
+						Args: []string{foundInst.cv},
+						Dest: inst.Dest,
+						Op:   &id,
+						Type: inst.Type,
+					}
+				}
+				varToTableIdx[*inst.Dest] = tableIdx
+			} else {
+				// Store original variable in environment so
+				// proceeding uses can still look up variable by
+				// original name not made up unique name (if we
+				// end up making on). Use len(table) because the
+				// entry is about to be added.
+				varToTableIdx[*inst.Dest] = len(table)
+
+				// Determine if variable is reused after this
+				// point, if so give it unique name. This is
+				// because the *value* may be re-used after the
+				// variable is clobbered in the environment. In
+				// that circumstance, we need a name to get at
+				// this previously computed value. In the
+				// environment the original name will point at
+				// this entry in the table up until it gets
+				// clobbered. Our pass will therefore re-write
+				// all uses to our unique name in the final
+				// output.
+				for _, afterInst := range block[blockIdx+1:] {
+					if afterInst.Dest != nil && *afterInst.Dest == *inst.Dest {
+						uniqueDest := fmt.Sprintf("lvn.%d", blockIdx)
+						inst.Dest = &uniqueDest
+						break
+					}
+				}
+
+				tableEntry := lvnTableEntry{}
+				if prop && *inst.Op == "id" && !assignedAfter(block, blockIdx, table[argTableIdxs[0]].cv) {
+					// If prop is on and we are storing an
+					// id instruction then uses can read the
+					// copied variable instead, as long as
+					// it isn't clobbered later in the block
+					tableEntry = lvnTableEntry{
+						inst: &tableInst,
+						cv:   table[argTableIdxs[0]].cv,
+					}
+				} else {
+					tableEntry = lvnTableEntry{
+						inst: &tableInst,
+						cv:   *inst.Dest, // we want to use the new name here
+					}
+				}
+				table = append(table, tableEntry)
+
+				// Rewrite args for this function to use the
+				// canonical variables. We want to use
+				// argTableIdxs rather than looking things up in
+				// the environment because we want the things
+				// from *before* we modified the environmet.
+				for argIdx, tableIdx := range argTableIdxs {
+					inst.Args[argIdx] = table[tableIdx].cv
+				}
+			}
+		} else {
+			for argIdx, arg := range inst.Args {
+				// Arguments from outside the block keep their
+				// names
+				if tableIdx, ok := varToTableIdx[arg]; ok {
+					inst.Args[argIdx] = table[tableIdx].cv
+				}
+			}
+		}
+		block[blockIdx] = inst
+	}
+	return nil
+}
+
+// assignedAfter - whether name is assigned after the instruction at index in
+// block
+func assignedAfter(block []models.Instruction, index int, name string) bool {
+	for _, inst := range block[index+1:] {
+		if inst.Dest != nil && *inst.Dest == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LVN numbers the values in each block of function separately. With prop
+// copies are propagated as well.
+func LVN(function models.Function, prop bool) (models.Function, error) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	for _, blockName := range namesInOrder {
+		// This will modify the block in place
+		if err := lvn(nameToBlock[blockName], prop); err != nil {
+			return function, fmt.Errorf("block %s: %w", blockName, err)
+		}
+	}
+	function.Instrs = utils.FlattenBlocks(namesInOrder, nameToBlock)
+	return function, nil
+}
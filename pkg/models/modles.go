@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 )
 
 type Program struct {
@@ -102,6 +103,19 @@ func (t *Type) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// String renders the type the way it's written in textual Bril, e.g. int or
+// ptr<int>.
+func (t Type) String() string {
+	switch {
+	case t.Primitive != nil:
+		return *t.Primitive
+	case t.Parameterized != nil:
+		return fmt.Sprintf("%s<%s>", t.Parameterized.Parameter, t.Parameterized.Type)
+	default:
+		return "?"
+	}
+}
+
 type ParameterizedType struct {
 	Parameter string
 	Type      Type
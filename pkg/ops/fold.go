@@ -0,0 +1,91 @@
+package ops
+
+import (
+	"fmt"
+	"math"
+)
+
+// Ints is the operations on two ints, the error is the one a program gets
+// at runtime.
+var Ints = map[string]func(a, b Value) (Value, error){
+	"add": func(a, b Value) (Value, error) { return IntValue(a.Int + b.Int), nil },
+	"sub": func(a, b Value) (Value, error) { return IntValue(a.Int - b.Int), nil },
+	"mul": func(a, b Value) (Value, error) { return IntValue(a.Int * b.Int), nil },
+	"div": func(a, b Value) (Value, error) {
+		if b.Int == 0 {
+			return Value{}, fmt.Errorf("division by zero")
+		}
+		// Go panics on this one overflowing case, wrap like the
+		// other operations do.
+		if a.Int == math.MinInt64 && b.Int == -1 {
+			return IntValue(a.Int), nil
+		}
+		return IntValue(a.Int / b.Int), nil
+	},
+	"eq": func(a, b Value) (Value, error) { return BoolValue(a.Int == b.Int), nil },
+	"lt": func(a, b Value) (Value, error) { return BoolValue(a.Int < b.Int), nil },
+	"gt": func(a, b Value) (Value, error) { return BoolValue(a.Int > b.Int), nil },
+	"le": func(a, b Value) (Value, error) { return BoolValue(a.Int <= b.Int), nil },
+	"ge": func(a, b Value) (Value, error) { return BoolValue(a.Int >= b.Int), nil },
+}
+
+// Floats is the operations on two floats.
+var Floats = map[string]func(a, b Value) (Value, error){
+	"fadd": func(a, b Value) (Value, error) { return FloatValue(a.Float + b.Float), nil },
+	"fsub": func(a, b Value) (Value, error) { return FloatValue(a.Float - b.Float), nil },
+	"fmul": func(a, b Value) (Value, error) { return FloatValue(a.Float * b.Float), nil },
+	"fdiv": func(a, b Value) (Value, error) { return FloatValue(a.Float / b.Float), nil },
+	"feq":  func(a, b Value) (Value, error) { return BoolValue(a.Float == b.Float), nil },
+	"flt":  func(a, b Value) (Value, error) { return BoolValue(a.Float < b.Float), nil },
+	"fgt":  func(a, b Value) (Value, error) { return BoolValue(a.Float > b.Float), nil },
+	"fle":  func(a, b Value) (Value, error) { return BoolValue(a.Float <= b.Float), nil },
+	"fge":  func(a, b Value) (Value, error) { return BoolValue(a.Float >= b.Float), nil },
+}
+
+// Fold evaluates a pure operation on already known values the same way the
+// interpreter would at runtime. This is what optimizations use for constant
+// folding. It returns false if op isn't a pure operation on values, the
+// arguments have the wrong types, or evaluating it would fail at runtime
+// (division by zero for example), in that case the operation has to be left
+// for runtime.
+func Fold(op string, args []Value) (Value, bool) {
+	allOfKind := func(k kind, n int) bool {
+		if len(args) != n {
+			return false
+		}
+		for _, arg := range args {
+			if arg.kind != k {
+				return false
+			}
+		}
+		return true
+	}
+
+	if fn, ok := Ints[op]; ok && allOfKind(intKind, 2) {
+		v, err := fn(args[0], args[1])
+		return v, err == nil
+	}
+	if fn, ok := Floats[op]; ok && allOfKind(floatKind, 2) {
+		v, err := fn(args[0], args[1])
+		return v, err == nil
+	}
+	switch op {
+	case "id":
+		if len(args) == 1 && args[0].kind != ptrKind {
+			return args[0], true
+		}
+	case "not":
+		if allOfKind(boolKind, 1) {
+			return BoolValue(!args[0].Bool), true
+		}
+	case "and":
+		if allOfKind(boolKind, 2) {
+			return BoolValue(args[0].Bool && args[1].Bool), true
+		}
+	case "or":
+		if allOfKind(boolKind, 2) {
+			return BoolValue(args[0].Bool || args[1].Bool), true
+		}
+	}
+	return Value{}, false
+}
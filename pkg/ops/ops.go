@@ -0,0 +1,24 @@
+// Package ops is bril's values and what the operations do to them. The
+// interpreter runs programs with it and the constant analyses fold with it,
+// so both agree on every result. It also sorts operations by the properties
+// optimizations care about.
+package ops
+
+import "aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+
+// Pure is the operations that compute a value from nothing but their
+// arguments, with no side effects. Anything else (call, load, alloc...) has
+// to be treated as producing a new value every time. const is left out, users
+// that want it check for it on their own.
+var Pure = utils.NewSet(
+	"add", "sub", "mul", "div",
+	"eq", "lt", "gt", "le", "ge",
+	"not", "and", "or",
+	"fadd", "fsub", "fmul", "fdiv",
+	"feq", "flt", "fgt", "fle", "fge",
+	"ptradd",
+)
+
+// Commutative is the operations whose arguments can be swapped, sorted
+// arguments are canonical for them.
+var Commutative = utils.NewSet("add", "mul", "eq", "and", "or", "fadd", "fmul", "feq")
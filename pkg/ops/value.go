@@ -0,0 +1,162 @@
+package ops
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+)
+
+type kind int
+
+const (
+	intKind kind = iota
+	boolKind
+	floatKind
+	ptrKind
+)
+
+// Value is a runtime value. Only the field matching kind is meaningful.
+type Value struct {
+	kind  kind
+	Int   int64
+	Bool  bool
+	Float float64
+	Ptr   Pointer
+}
+
+// Pointer is a location in the heap along with the type of thing it points
+// at. The type is needed to check loads and stores.
+type Pointer struct {
+	Base   int
+	Offset int64
+	Type   models.Type
+}
+
+func IntValue(i int64) Value {
+	return Value{kind: intKind, Int: i}
+}
+
+func BoolValue(b bool) Value {
+	return Value{kind: boolKind, Bool: b}
+}
+
+func FloatValue(f float64) Value {
+	return Value{kind: floatKind, Float: f}
+}
+
+func PtrValue(p Pointer) Value {
+	return Value{kind: ptrKind, Ptr: p}
+}
+
+// String formats the value the same way the reference interpreter prints it.
+func (v Value) String() string {
+	switch v.kind {
+	case intKind:
+		return strconv.FormatInt(v.Int, 10)
+	case boolKind:
+		return strconv.FormatBool(v.Bool)
+	case floatKind:
+		switch {
+		case math.IsNaN(v.Float):
+			return "NaN"
+		case math.IsInf(v.Float, 1):
+			return "Infinity"
+		case math.IsInf(v.Float, -1):
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v.Float, 'f', 17, 64)
+	case ptrKind:
+		return fmt.Sprintf("<ptr %d+%d>", v.Ptr.Base, v.Ptr.Offset)
+	}
+	return "?"
+}
+
+// Pointer - the pointer v holds, ok is false if v isn't a pointer
+func (v Value) Pointer() (p Pointer, ok bool) {
+	return v.Ptr, v.kind == ptrKind
+}
+
+// Model converts an int, bool or float back into the value of a const
+// instruction. Pointers have no literal form, and neither do ints that a
+// float64 can't hold exactly since that's how literals are stored, or
+// infinities and NaN which JSON can't write.
+func (v Value) Model() (models.Value, bool) {
+	switch v.kind {
+	case intKind:
+		f := float64(v.Int)
+		if f >= math.MaxInt64 || int64(f) != v.Int {
+			return models.Value{}, false
+		}
+		return models.Value{Float: &f}, true
+	case floatKind:
+		if math.IsInf(v.Float, 0) || math.IsNaN(v.Float) {
+			return models.Value{}, false
+		}
+		f := v.Float
+		return models.Value{Float: &f}, true
+	case boolKind:
+		b := v.Bool
+		return models.Value{Bool: &b}, true
+	}
+	return models.Value{}, false
+}
+
+// Equal is == except that floats are compared bit for bit. 0 and -0 print
+// differently and divide to different infinities, and NaN has to be equal to
+// itself or a variable holding it would look like it changes every time it's
+// computed.
+func (v Value) Equal(o Value) bool {
+	if v.kind == floatKind && o.kind == floatKind {
+		return math.Float64bits(v.Float) == math.Float64bits(o.Float)
+	}
+	return v == o
+}
+
+// HasType checks that a runtime value is a member of a declared type.
+func HasType(v Value, t models.Type) bool {
+	if t.Parameterized != nil {
+		return v.kind == ptrKind &&
+			t.Parameterized.Parameter == "ptr" &&
+			v.Ptr.Type.String() == t.Parameterized.Type.String()
+	}
+	if t.Primitive == nil {
+		return false
+	}
+	switch *t.Primitive {
+	case "int":
+		return v.kind == intKind
+	case "bool":
+		return v.kind == boolKind
+	case "float":
+		return v.kind == floatKind
+	}
+	return false
+}
+
+// Literal converts a const instruction's value to a runtime value. JSON only
+// gives us float64s so the declared type decides whether this is an int.
+func Literal(inst models.Instruction) (Value, error) {
+	if inst.Value == nil || inst.Type == nil || inst.Type.Primitive == nil {
+		return Value{}, fmt.Errorf("const instruction must have a primitive type and value")
+	}
+	switch *inst.Type.Primitive {
+	case "int":
+		if inst.Value.Float == nil {
+			return Value{}, fmt.Errorf("const of type int must have a numeric value")
+		}
+		return IntValue(int64(*inst.Value.Float)), nil
+	case "float":
+		if inst.Value.Float == nil {
+			return Value{}, fmt.Errorf("const of type float must have a numeric value")
+		}
+		return FloatValue(*inst.Value.Float), nil
+	case "bool":
+		if inst.Value.Bool == nil {
+			return Value{}, fmt.Errorf("const of type bool must have a boolean value")
+		}
+		return BoolValue(*inst.Value.Bool), nil
+	}
+	return Value{}, fmt.Errorf("unknown const type %s", *inst.Type.Primitive)
+}
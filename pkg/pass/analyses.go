@@ -0,0 +1,216 @@
+package pass
+
+import (
+	"fmt"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/df"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/dominators"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lattice"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/liveness"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// Analysis is a set of analyses that can be cached, a pass says which ones
+// it keeps right.
+type Analysis int
+
+const (
+	// CFG is the block names, in order, and the control flow graph
+	CFG Analysis = 1 << iota
+	// Dominators is the dominators of every block and the dominator tree
+	Dominators
+	// Liveness is the variables live into and out of every block
+	Liveness
+
+	None Analysis = 0
+	All           = CFG | Dominators | Liveness
+)
+
+var analysisNames = []struct {
+	analysis Analysis
+	name     string
+}{
+	{CFG, "cfg"},
+	{Dominators, "dominators"},
+	{Liveness, "liveness"},
+}
+
+func (a Analysis) String() string {
+	var names []string
+	for _, n := range analysisNames {
+		if a&n.analysis != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// Stats counts how many times each analysis was computed and how many times
+// a cached one was used instead.
+type Stats struct {
+	Computed map[Analysis]int
+	Reused   map[Analysis]int
+}
+
+func newStats() Stats {
+	return Stats{Computed: make(map[Analysis]int), Reused: make(map[Analysis]int)}
+}
+
+func (s Stats) String() string {
+	var lines []string
+	for _, n := range analysisNames {
+		lines = append(lines, fmt.Sprintf("%s: computed %d, reused %d", n.name, s.Computed[n.analysis], s.Reused[n.analysis]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Analyses has the analyses of one function, each is computed the first time
+// it's asked for and kept until a pass changes the function without
+// preserving it.
+type Analyses struct {
+	function models.Function
+	stats    *Stats
+
+	namesInOrder []string
+	cfg          utils.Digraph
+
+	dominators map[string]utils.Set
+	tree       utils.Digraph
+
+	liveness map[string]*df.ProgramPoint[lattice.UnionMeetBitSetLattice]
+}
+
+// NewAnalyses - analyses of function outside of a Manager
+func NewAnalyses(function models.Function) *Analyses {
+	stats := newStats()
+	return &Analyses{function: function, stats: &stats}
+}
+
+// invalidate drops every analysis not in preserved. Dominators are
+// computed from the CFG so they go with it.
+func (a *Analyses) invalidate(preserved Analysis) {
+	if preserved&CFG == 0 {
+		a.namesInOrder, a.cfg = nil, nil
+		preserved &^= Dominators
+	}
+	if preserved&Dominators == 0 {
+		a.dominators, a.tree = nil, nil
+	}
+	if preserved&Liveness == 0 {
+		a.liveness = nil
+	}
+}
+
+// update makes a the analyses of function, which a pass that preserves
+// preserved made. Even a pass that leaves the control flow alone can empty or
+// add an unlabeled block, giving the blocks after it new names, so the CFG is
+// only kept if the names are the same.
+func (a *Analyses) update(function models.Function, preserved Analysis) {
+	a.function = function
+	if preserved&CFG != 0 && a.namesInOrder != nil {
+		namesInOrder, _ := a.Blocks()
+		if !equal(namesInOrder, a.namesInOrder) {
+			preserved = None
+		}
+	}
+	a.invalidate(preserved)
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Analyses) count(analysis Analysis, cached bool) {
+	if cached {
+		a.stats.Reused[analysis]++
+	} else {
+		a.stats.Computed[analysis]++
+	}
+}
+
+// Blocks - the basic blocks of the function. They aren't cached, a pass that
+// preserves the CFG can still change the instructions in a block.
+func (a *Analyses) Blocks() (namesInOrder []string, nameToBlock map[string][]models.Instruction) {
+	return utils.BasicBlocks(a.function)
+}
+
+// CFG - the block names of the function in order and its control flow graph
+func (a *Analyses) CFG() ([]string, utils.Digraph, error) {
+	cached := a.cfg != nil
+	a.count(CFG, cached)
+	if cached {
+		return a.namesInOrder, a.cfg, nil
+	}
+	namesInOrder, nameToBlock := a.Blocks()
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		return nil, nil, err
+	}
+	a.namesInOrder, a.cfg = namesInOrder, cfg
+	return namesInOrder, cfg, nil
+}
+
+func (a *Analyses) computeDominators() error {
+	cached := a.dominators != nil
+	a.count(Dominators, cached)
+	if cached {
+		return nil
+	}
+	namesInOrder, cfg, err := a.CFG()
+	if err != nil {
+		return err
+	}
+	a.dominators = dominators.Dominators(namesInOrder, cfg)
+	a.tree = dominators.Tree(namesInOrder, cfg)
+	return nil
+}
+
+// Dominators - the blocks that dominate each block
+func (a *Analyses) Dominators() (map[string]utils.Set, error) {
+	if err := a.computeDominators(); err != nil {
+		return nil, err
+	}
+	return a.dominators, nil
+}
+
+// DominatorTree - an edge from every block to those it immediately dominates
+func (a *Analyses) DominatorTree() (utils.Digraph, error) {
+	if err := a.computeDominators(); err != nil {
+		return nil, err
+	}
+	return a.tree, nil
+}
+
+// Liveness - the variables live at the start and end of each block
+func (a *Analyses) Liveness() (map[string]*df.ProgramPoint[lattice.UnionMeetBitSetLattice], error) {
+	cached := a.liveness != nil
+	a.count(Liveness, cached)
+	if cached {
+		return a.liveness, nil
+	}
+
+	namesInOrder, cfg, err := a.CFG()
+	if err != nil {
+		return nil, err
+	}
+	_, nameToBlock := a.Blocks()
+	live, err := liveness.Live(a.function, namesInOrder, nameToBlock, cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.liveness = live
+	return live, nil
+}
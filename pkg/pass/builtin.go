@@ -0,0 +1,172 @@
+package pass
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/adce"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/gvn"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/licm"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/lvn"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/sccp"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ssa"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/tdce"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/validate"
+)
+
+// builtins - the passes Lookup knows by name. Passes that only change the
+// instructions inside blocks keep the control flow, and with it the
+// dominators. Liveness only survives a pass that changes nothing.
+var builtins = map[string]Pass{
+	"to-ssa": Function{PassName: "to-ssa", Preserved: CFG | Dominators, Run: toSSA},
+	"from-ssa": transform("from-ssa", None, func(function models.Function, a *Analyses) (models.Function, error) {
+		namesInOrder, nameToBlock, cfg, err := blocks(a)
+		if err != nil {
+			return function, err
+		}
+		return ssa.Destruct(function, namesInOrder, nameToBlock, cfg)
+	}),
+	"sccp": transform("sccp", None, func(function models.Function, a *Analyses) (models.Function, error) {
+		namesInOrder, nameToBlock, cfg, err := blocks(a)
+		if err != nil {
+			return function, err
+		}
+		return sccp.Propagate(function, namesInOrder, nameToBlock, cfg)
+	}),
+	"gvn": transform("gvn", CFG|Dominators, func(function models.Function, a *Analyses) (models.Function, error) {
+		namesInOrder, nameToBlock, cfg, err := blocks(a)
+		if err != nil || len(namesInOrder) == 0 {
+			return function, err
+		}
+		tree, err := a.DominatorTree()
+		if err != nil {
+			return function, err
+		}
+		return gvn.Number(function, namesInOrder, nameToBlock, cfg, tree)
+	}),
+	"adce": transform("adce", None, func(function models.Function, a *Analyses) (models.Function, error) {
+		namesInOrder, nameToBlock, cfg, err := blocks(a)
+		if err != nil {
+			return function, err
+		}
+		return adce.Sweep(function, namesInOrder, nameToBlock, cfg)
+	}),
+	"licm": transform("licm", None, func(function models.Function, a *Analyses) (models.Function, error) {
+		namesInOrder, nameToBlock, cfg, err := blocks(a)
+		if err != nil || len(namesInOrder) == 0 {
+			return function, err
+		}
+		nameToDominators, err := a.Dominators()
+		if err != nil {
+			return function, err
+		}
+		live, err := a.Liveness()
+		if err != nil {
+			return function, err
+		}
+		return licm.Hoist(function, namesInOrder, nameToBlock, cfg, nameToDominators, live)
+	}),
+	"lvn": transform("lvn", CFG|Dominators, func(function models.Function, _ *Analyses) (models.Function, error) {
+		return lvn.LVN(function, false)
+	}),
+	"lvn-prop": transform("lvn-prop", CFG|Dominators, func(function models.Function, _ *Analyses) (models.Function, error) {
+		return lvn.LVN(function, true)
+	}),
+	"tdce": Function{PassName: "tdce", Preserved: CFG | Dominators, Run: func(function models.Function, _ *Analyses) (models.Function, bool, error) {
+		function, changed := tdce.TDCE(function)
+		return function, changed, nil
+	}},
+	"dkl": Function{PassName: "dkl", Preserved: CFG | Dominators, Run: func(function models.Function, _ *Analyses) (models.Function, bool, error) {
+		function, changed := tdce.DKL(function)
+		return function, changed, nil
+	}},
+
+	// Assertions, they stop the pipeline if the program is wrong
+	"ssa-check": Function{PassName: "ssa-check", Preserved: All, Run: func(function models.Function, _ *Analyses) (models.Function, bool, error) {
+		return function, false, ssa.Check(function)
+	}},
+	"validate": Module{PassName: "validate", Run: func(prog models.Program) (models.Program, bool, error) {
+		diagnostics := validate.Program(prog)
+		switch len(diagnostics) {
+		case 0:
+			return prog, false, nil
+		case 1:
+			return prog, false, fmt.Errorf("%s", diagnostics[0])
+		}
+		return prog, false, fmt.Errorf("%s (and %d more)", diagnostics[0], len(diagnostics)-1)
+	}},
+}
+
+// Lookup finds a built in pass by name, for Parse.
+func Lookup(name string) (Pass, bool) {
+	p, ok := builtins[name]
+	return p, ok
+}
+
+// Names - the names of the built in passes, sorted
+func Names() []string {
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// toSSA leaves a function that's already in SSA form alone, renaming its
+// variables again would be a change every time and a fixpoint group with it
+// would never stop.
+func toSSA(function models.Function, a *Analyses) (models.Function, bool, error) {
+	namesInOrder, nameToBlock, cfg, err := blocks(a)
+	if err != nil || len(namesInOrder) == 0 {
+		return function, false, err
+	}
+	violations, err := ssa.Verify(function)
+	if err != nil {
+		return function, false, err
+	}
+	if len(violations) == 0 {
+		return function, false, nil
+	}
+	tree, err := a.DominatorTree()
+	if err != nil {
+		return function, false, err
+	}
+	function, err = ssa.Construct(function, namesInOrder, nameToBlock, cfg, tree)
+	if err != nil {
+		return function, false, err
+	}
+	return function, true, nil
+}
+
+// blocks - the blocks of the function a is for and its control flow graph
+func blocks(a *Analyses) ([]string, map[string][]models.Instruction, utils.Digraph, error) {
+	_, nameToBlock := a.Blocks()
+	namesInOrder, cfg, err := a.CFG()
+	return namesInOrder, nameToBlock, cfg, err
+}
+
+// transform makes a pass from a transformation that doesn't say whether it
+// changed anything. Transformations can modify the instructions they're
+// given so the function is compared as JSON from before it's run.
+func transform(name string, preserved Analysis, t func(models.Function, *Analyses) (models.Function, error)) Function {
+	return Function{PassName: name, Preserved: preserved, Run: func(function models.Function, a *Analyses) (models.Function, bool, error) {
+		before, err := json.Marshal(function)
+		if err != nil {
+			return function, false, err
+		}
+		out, err := t(function, a)
+		if err != nil {
+			return function, false, err
+		}
+		after, err := json.Marshal(out)
+		if err != nil {
+			return function, false, err
+		}
+		return out, !bytes.Equal(before, after), nil
+	}}
+}
@@ -0,0 +1,182 @@
+// Package pass runs a pipeline of passes over a program without going back
+// to JSON in between. Function passes see one function at a time along with
+// the analyses already done on it, module passes see the whole program.
+// Analyses are kept until a pass changes a function without preserving them.
+package pass
+
+import (
+	"errors"
+	"fmt"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+)
+
+// ErrNoFixpoint is returned when a Fixpoint group is still changing the
+// program after its MaxIterations.
+var ErrNoFixpoint = errors.New("passes did not reach a fixpoint")
+
+// Pass is a FunctionPass, a ModulePass or a Fixpoint group of passes.
+type Pass interface {
+	Name() string
+}
+
+// FunctionPass transforms one function at a time.
+type FunctionPass interface {
+	Pass
+	// RunOnFunction returns the new function and whether it's any
+	// different. The analyses are those of the function passed in.
+	RunOnFunction(function models.Function, a *Analyses) (models.Function, bool, error)
+	// Preserves is the analyses still right after the pass changes a
+	// function
+	Preserves() Analysis
+}
+
+// ModulePass transforms the whole program. Every analysis is dropped when a
+// module pass changes the program.
+type ModulePass interface {
+	Pass
+	RunOnModule(prog models.Program) (models.Program, bool, error)
+}
+
+// Function makes a FunctionPass from Run.
+type Function struct {
+	PassName  string
+	Preserved Analysis
+	Run       func(function models.Function, a *Analyses) (models.Function, bool, error)
+}
+
+func (f Function) Name() string {
+	return f.PassName
+}
+
+func (f Function) RunOnFunction(function models.Function, a *Analyses) (models.Function, bool, error) {
+	return f.Run(function, a)
+}
+
+func (f Function) Preserves() Analysis {
+	return f.Preserved
+}
+
+// Module makes a ModulePass from Run.
+type Module struct {
+	PassName string
+	Run      func(prog models.Program) (models.Program, bool, error)
+}
+
+func (m Module) Name() string {
+	return m.PassName
+}
+
+func (m Module) RunOnModule(prog models.Program) (models.Program, bool, error) {
+	return m.Run(prog)
+}
+
+// Fixpoint runs Passes in order over and over until none of them change the
+// program. MaxIterations is how many times they can be run, 0 for no limit.
+type Fixpoint struct {
+	Passes        []Pass
+	MaxIterations int
+}
+
+func (f Fixpoint) Name() string {
+	name := "fixpoint("
+	for i, p := range f.Passes {
+		if i != 0 {
+			name += ","
+		}
+		name += p.Name()
+	}
+	return name + ")"
+}
+
+// Manager runs passes, keeping the analyses of every function between them.
+type Manager struct {
+	// nameToAnalyses has the analyses of each function by name
+	nameToAnalyses map[string]*Analyses
+	Stats          Stats
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		nameToAnalyses: make(map[string]*Analyses),
+		Stats:          newStats(),
+	}
+}
+
+// Run runs passes in order over prog, the first error stops the pipeline.
+func (m *Manager) Run(prog models.Program, passes []Pass) (models.Program, error) {
+	prog, _, err := m.run(prog, passes)
+	return prog, err
+}
+
+func (m *Manager) run(prog models.Program, passes []Pass) (models.Program, bool, error) {
+	changed := false
+	for _, p := range passes {
+		var passChanged bool
+		var err error
+		switch p := p.(type) {
+		case FunctionPass:
+			prog, passChanged, err = m.runFunctionPass(prog, p)
+		case ModulePass:
+			prog, passChanged, err = p.RunOnModule(prog)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", p.Name(), err)
+			}
+			if passChanged {
+				m.nameToAnalyses = make(map[string]*Analyses)
+			}
+		case Fixpoint:
+			prog, passChanged, err = m.runFixpoint(prog, p)
+		default:
+			err = fmt.Errorf("%s: unknown kind of pass %T", p.Name(), p)
+		}
+		if err != nil {
+			return prog, changed, err
+		}
+		changed = changed || passChanged
+	}
+	return prog, changed, nil
+}
+
+func (m *Manager) runFunctionPass(prog models.Program, p FunctionPass) (models.Program, bool, error) {
+	changed := false
+	for i, function := range prog.Functions {
+		a, ok := m.nameToAnalyses[function.Name]
+		if !ok {
+			a = &Analyses{stats: &m.Stats}
+			m.nameToAnalyses[function.Name] = a
+		}
+		a.function = function
+
+		out, functionChanged, err := p.RunOnFunction(function, a)
+		if err != nil {
+			return prog, changed, fmt.Errorf("%s: @%s: %w", p.Name(), function.Name, err)
+		}
+		if functionChanged {
+			a.update(out, p.Preserves())
+		}
+		// The for loop uses value semantics, we aren't modifying the
+		// real function. To replace it we have to index into the
+		// functions array.
+		prog.Functions[i] = out
+		changed = changed || functionChanged
+	}
+	return prog, changed, nil
+}
+
+func (m *Manager) runFixpoint(prog models.Program, f Fixpoint) (models.Program, bool, error) {
+	changed := false
+	for i := 0; f.MaxIterations == 0 || i < f.MaxIterations; i++ {
+		var groupChanged bool
+		var err error
+		prog, groupChanged, err = m.run(prog, f.Passes)
+		if err != nil {
+			return prog, changed, err
+		}
+		if !groupChanged {
+			return prog, changed, nil
+		}
+		changed = true
+	}
+	return prog, changed, fmt.Errorf("%w: %s after %d iterations", ErrNoFixpoint, f.Name(), f.MaxIterations)
+}
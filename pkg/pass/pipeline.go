@@ -0,0 +1,85 @@
+package pass
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrBadPipeline is returned by Parse for a pipeline it can't make sense of.
+var ErrBadPipeline = errors.New("bad pipeline")
+
+// MaxIterations is the MaxIterations of the Fixpoint groups made by Parse
+const MaxIterations = 100
+
+// Parse reads a pipeline of comma separated pass names, lookup finds the
+// pass for a name. fixpoint(a,b) is a Fixpoint group running a and b until
+// neither changes anything, at most MaxIterations times. Groups can be
+// nested.
+func Parse(pipeline string, lookup func(name string) (Pass, bool)) ([]Pass, error) {
+	p := parser{pipeline: pipeline, lookup: lookup}
+	passes, err := p.passes()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.pipeline) {
+		return nil, fmt.Errorf("%w: unexpected %q at %d", ErrBadPipeline, p.pipeline[p.pos], p.pos)
+	}
+	return passes, nil
+}
+
+type parser struct {
+	pipeline string
+	pos      int
+	lookup   func(name string) (Pass, bool)
+}
+
+// passes reads passes up to the end of the pipeline or a closing
+// parenthesis, whichever comes first.
+func (p *parser) passes() ([]Pass, error) {
+	var passes []Pass
+	for {
+		pass, err := p.pass()
+		if err != nil {
+			return nil, err
+		}
+		passes = append(passes, pass)
+		if p.pos == len(p.pipeline) || p.pipeline[p.pos] != ',' {
+			return passes, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) pass() (Pass, error) {
+	start := p.pos
+	for p.pos < len(p.pipeline) && !strings.ContainsRune(",()", rune(p.pipeline[p.pos])) {
+		p.pos++
+	}
+	name := strings.TrimSpace(p.pipeline[start:p.pos])
+	if name == "" {
+		return nil, fmt.Errorf("%w: missing pass name at %d", ErrBadPipeline, start)
+	}
+
+	if p.pos < len(p.pipeline) && p.pipeline[p.pos] == '(' {
+		if name != "fixpoint" {
+			return nil, fmt.Errorf("%w: %s can't have passes", ErrBadPipeline, name)
+		}
+		p.pos++
+		passes, err := p.passes()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos == len(p.pipeline) || p.pipeline[p.pos] != ')' {
+			return nil, fmt.Errorf("%w: missing ) for the fixpoint at %d", ErrBadPipeline, start)
+		}
+		p.pos++
+		return Fixpoint{Passes: passes, MaxIterations: MaxIterations}, nil
+	}
+
+	pass, ok := p.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown pass %s", ErrBadPipeline, name)
+	}
+	return pass, nil
+}
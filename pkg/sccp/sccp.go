@@ -0,0 +1,329 @@
+// Package sccp implements sparse conditional constant propagation (Wegman &
+// Zadeck) for functions in SSA form.
+package sccp
+
+import (
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ops"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ssa"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+type state int
+
+const (
+	// top - nothing is known yet, the definition hasn't been reached.
+	top state = iota
+	constant
+	// bottom - the variable can hold more than one value at runtime.
+	bottom
+)
+
+type cell struct {
+	state state
+	value ops.Value
+}
+
+func meet(a, b cell) cell {
+	switch {
+	case a.state == top:
+		return b
+	case b.state == top:
+		return a
+	case a.state == bottom || b.state == bottom:
+		return cell{state: bottom}
+	case !a.value.Equal(b.value):
+		return cell{state: bottom}
+	}
+	return a
+}
+
+type edge struct {
+	from string
+	to   string
+}
+
+type solver struct {
+	nameToBlock map[string][]models.Instruction
+	cfg         utils.Digraph
+
+	values           map[string]cell
+	uses             map[string][]utils.Site
+	executableEdges  map[edge]bool
+	executableBlocks utils.Set
+
+	flowWorkList []edge
+	ssaWorkList  []utils.Site
+}
+
+// SCCP finds variables that are constant on every executable path through
+// function, which must be in SSA form. Their definitions are replaced by
+// constants, branches on constant conditions become jumps and blocks that can
+// never execute are removed.
+//
+// Only the definitions are rewritten, uses are left alone, running a dead
+// code elimination pass afterwards will clean up what is no longer needed.
+func SCCP(function models.Function) (models.Function, error) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	if len(namesInOrder) == 0 {
+		return function, nil
+	}
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		return function, err
+	}
+	return Propagate(function, namesInOrder, nameToBlock, cfg)
+}
+
+// Propagate is SCCP for when the blocks of function and its control flow
+// graph are already known.
+func Propagate(function models.Function, namesInOrder []string, nameToBlock map[string][]models.Instruction, cfg utils.Digraph) (models.Function, error) {
+	if len(namesInOrder) == 0 {
+		return function, nil
+	}
+	s := &solver{
+		nameToBlock:      nameToBlock,
+		cfg:              cfg,
+		values:           make(map[string]cell),
+		uses:             make(map[string][]utils.Site),
+		executableEdges:  make(map[edge]bool),
+		executableBlocks: utils.NewSet(),
+	}
+
+	// def-use chains, in SSA form every variable has exactly one
+	// definition so all we need are the uses.
+	for _, name := range namesInOrder {
+		for i, inst := range nameToBlock[name] {
+			for _, arg := range inst.Args {
+				s.uses[arg] = append(s.uses[arg], utils.Site{Block: name, Index: i})
+			}
+		}
+	}
+
+	// Arguments could be anything
+	for _, arg := range function.Args {
+		s.values[arg.Name] = cell{state: bottom}
+	}
+
+	// The entry block is executable, give it an edge from nowhere.
+	s.flowWorkList = append(s.flowWorkList, edge{to: namesInOrder[0]})
+	for len(s.flowWorkList) != 0 || len(s.ssaWorkList) != 0 {
+		if len(s.flowWorkList) != 0 {
+			e := s.flowWorkList[0]
+			s.flowWorkList = s.flowWorkList[1:]
+			s.visitEdge(e)
+			continue
+		}
+		st := s.ssaWorkList[0]
+		s.ssaWorkList = s.ssaWorkList[1:]
+		if s.executableBlocks.Contains(st.Block) {
+			s.visit(st)
+		}
+	}
+
+	var outNames []string
+	for _, name := range namesInOrder {
+		if s.executableBlocks.Contains(name) {
+			outNames = append(outNames, name)
+			nameToBlock[name] = s.rewrite(name)
+		}
+	}
+	function.Instrs = utils.FlattenBlocks(outNames, nameToBlock)
+	return function, nil
+}
+
+func (s *solver) visitEdge(e edge) {
+	if s.executableEdges[e] {
+		return
+	}
+	s.executableEdges[e] = true
+
+	block := s.nameToBlock[e.to]
+	if !s.executableBlocks.Contains(e.to) {
+		s.executableBlocks.Add(e.to)
+		for i := range block {
+			s.visit(utils.Site{Block: e.to, Index: i})
+		}
+		// Blocks that don't end in a jump fall through
+		if len(block) == 0 || !isTerminator(block[len(block)-1]) {
+			for _, succ := range utils.Successors(s.cfg, e.to) {
+				s.flowWorkList = append(s.flowWorkList, edge{from: e.to, to: succ})
+			}
+		}
+		return
+	}
+
+	// We've already been here, the only thing a new edge changes is the
+	// phi nodes.
+	for i, inst := range block {
+		if isPhi(inst) {
+			s.visit(utils.Site{Block: e.to, Index: i})
+		}
+	}
+}
+
+func (s *solver) visit(st utils.Site) {
+	inst := s.nameToBlock[st.Block][st.Index]
+	if inst.Op == nil {
+		return
+	}
+
+	switch *inst.Op {
+	case "jmp":
+		s.flowWorkList = append(s.flowWorkList, edge{from: st.Block, to: inst.Labels[0]})
+	case "br":
+		cond := s.value(inst.Args[0])
+		switch {
+		case cond.state == bottom:
+			s.flowWorkList = append(s.flowWorkList,
+				edge{from: st.Block, to: inst.Labels[0]},
+				edge{from: st.Block, to: inst.Labels[1]})
+		case cond.state == constant && cond.value.Bool:
+			s.flowWorkList = append(s.flowWorkList, edge{from: st.Block, to: inst.Labels[0]})
+		case cond.state == constant:
+			s.flowWorkList = append(s.flowWorkList, edge{from: st.Block, to: inst.Labels[1]})
+		}
+	case "phi":
+		c := cell{state: top}
+		for i, label := range inst.Labels {
+			if s.executableEdges[edge{from: label, to: st.Block}] && inst.Args[i] != ssa.Undefined {
+				c = meet(c, s.value(inst.Args[i]))
+			}
+		}
+		s.update(*inst.Dest, c)
+	default:
+		if inst.Dest != nil {
+			s.update(*inst.Dest, s.evaluate(inst))
+		}
+	}
+}
+
+func (s *solver) evaluate(inst models.Instruction) cell {
+	switch *inst.Op {
+	case "const":
+		v, err := ops.Literal(inst)
+		if err != nil {
+			return cell{state: bottom}
+		}
+		return cell{state: constant, value: v}
+	case "call", "load", "alloc", "ptradd":
+		return cell{state: bottom}
+	}
+
+	var args []ops.Value
+	for _, arg := range inst.Args {
+		c := s.value(arg)
+		switch c.state {
+		case bottom:
+			return c
+		case top:
+			// We'll come back to this when the argument is
+			// better known.
+			return c
+		}
+		args = append(args, c.value)
+	}
+	if v, ok := ops.Fold(*inst.Op, args); ok {
+		return cell{state: constant, value: v}
+	}
+	return cell{state: bottom}
+}
+
+func (s *solver) value(name string) cell {
+	if c, ok := s.values[name]; ok {
+		return c
+	}
+	return cell{state: top}
+}
+
+func (s *solver) update(name string, c cell) {
+	if old := s.value(name); old == c {
+		return
+	}
+	s.values[name] = c
+	s.ssaWorkList = append(s.ssaWorkList, s.uses[name]...)
+}
+
+// rewrite applies what the solver found to an executable block
+func (s *solver) rewrite(name string) []models.Instruction {
+	var phis, consts, rest []models.Instruction
+	for _, inst := range s.nameToBlock[name] {
+		if inst.Op == nil {
+			rest = append(rest, inst)
+			continue
+		}
+
+		c := cell{state: bottom}
+		if inst.Dest != nil {
+			c = s.value(*inst.Dest)
+		}
+		op := *inst.Op
+		v, literal := c.value.Model()
+		switch {
+		case c.state == constant && literal && op != "const" && op != "call":
+			// Constant phi nodes are moved below the remaining
+			// phi nodes so those stay at the start of the block.
+			constOp := "const"
+			constInst := models.Instruction{
+				Dest:  inst.Dest,
+				Op:    &constOp,
+				Type:  inst.Type,
+				Value: &v,
+			}
+			if op == "phi" {
+				consts = append(consts, constInst)
+			} else {
+				rest = append(rest, constInst)
+			}
+			continue
+		case op == "phi":
+			// Drop the arguments for edges that never execute,
+			// the blocks they come from may be gone.
+			var args, labels []string
+			for i, label := range inst.Labels {
+				if s.executableEdges[edge{from: label, to: name}] {
+					args = append(args, inst.Args[i])
+					labels = append(labels, label)
+				}
+			}
+			inst.Args = args
+			inst.Labels = labels
+			phis = append(phis, inst)
+			continue
+		case op == "br":
+			if cond := s.value(inst.Args[0]); cond.state == constant {
+				target := inst.Labels[1]
+				if cond.value.Bool {
+					target = inst.Labels[0]
+				}
+				jmp := "jmp"
+				rest = append(rest, models.Instruction{Op: &jmp, Labels: []string{target}})
+				continue
+			}
+		}
+		if op == "phi" {
+			phis = append(phis, inst)
+		} else {
+			rest = append(rest, inst)
+		}
+	}
+
+	// Keep the label first
+	var out []models.Instruction
+	if len(rest) != 0 && rest[0].Op == nil {
+		out = append(out, rest[0])
+		rest = rest[1:]
+	}
+	out = append(out, phis...)
+	out = append(out, consts...)
+	out = append(out, rest...)
+	return out
+}
+
+func isPhi(inst models.Instruction) bool {
+	return inst.Op != nil && *inst.Op == "phi"
+}
+
+func isTerminator(inst models.Instruction) bool {
+	return inst.Op != nil && (*inst.Op == "jmp" || *inst.Op == "br" || *inst.Op == "ret")
+}
@@ -0,0 +1,311 @@
+// Package ssa has utilities for working with programs in SSA form.
+package ssa
+
+import (
+	"fmt"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// Undefined is the placeholder phi argument to-ssa uses when a variable
+// isn't defined along the path from a predecessor.
+const Undefined = "__undefined"
+
+type edge struct {
+	from string
+	to   string
+}
+
+type copyInst struct {
+	dest string
+	src  string
+	t    models.Type
+}
+
+// FromSSA converts function out of SSA form. Every phi node is removed and
+// replaced by copies at the end of the predecessor blocks the phi node
+// refers to. Critical edges (an edge from a block with more than one
+// successor to a block with more than one predecessor) are split so that a
+// copy never executes on a path where the phi wouldn't have.
+//
+// All the copies for a single edge are treated as happening in parallel, just
+// like phi nodes at the start of a block. This fixes the "swap problem" where
+// one phi reads the destination of another.
+//
+// A phi that can be given Undefined leaves its destination undefined on that
+// path, and a copy from it along another edge would read an undefined
+// variable. Those destinations are given a placeholder value at the start of
+// the function, the program never reads it.
+func FromSSA(function models.Function) (models.Function, error) {
+	if !hasPhi(function) {
+		return function, nil
+	}
+
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		return function, err
+	}
+	return Destruct(function, namesInOrder, nameToBlock, cfg)
+}
+
+// Destruct is FromSSA for when the blocks of function and its control flow
+// graph are already known.
+func Destruct(function models.Function, namesInOrder []string, nameToBlock map[string][]models.Instruction, cfg utils.Digraph) (models.Function, error) {
+	if !hasPhi(function) {
+		return function, nil
+	}
+	// Split blocks are added to the end of the function, we don't want
+	// the previously last block to fall through to them.
+	namesInOrder, nameToBlock, err := utils.AddRet(namesInOrder, nameToBlock)
+	if err != nil {
+		return function, err
+	}
+	fresh := newNamer(function)
+
+	// Gather up the copies needed along each edge while dropping the phi
+	// nodes themselves.
+	edgeToCopies := make(map[edge][]copyInst)
+	var edges []edge
+	for _, name := range namesInOrder {
+		preds := utils.Predecessors(cfg, name)
+		var block []models.Instruction
+		for _, inst := range nameToBlock[name] {
+			if inst.Op == nil || *inst.Op != "phi" {
+				block = append(block, inst)
+				continue
+			}
+			for i, label := range inst.Labels {
+				// If the variable isn't defined along this path
+				// leaving the destination alone is just as
+				// undefined as the phi would have been.
+				if i >= len(inst.Args) || inst.Args[i] == Undefined || !utils.Contains(preds, label) {
+					continue
+				}
+				e := edge{from: label, to: name}
+				if _, ok := edgeToCopies[e]; !ok {
+					edges = append(edges, e)
+				}
+				edgeToCopies[e] = append(edgeToCopies[e], copyInst{
+					dest: *inst.Dest,
+					src:  inst.Args[i],
+					t:    *inst.Type,
+				})
+			}
+		}
+		nameToBlock[name] = block
+	}
+
+	for _, e := range edges {
+		copies := sequentialize(edgeToCopies[e], fresh)
+		if len(copies) == 0 {
+			continue
+		}
+
+		if len(utils.Successors(cfg, e.from)) > 1 {
+			// Critical edge, put the copies in a new block of
+			// their own between the two.
+			split := fresh(e.from + "." + e.to)
+			jmp := "jmp"
+			label := split
+			block := []models.Instruction{{Label: &label}}
+			block = append(block, copies...)
+			block = append(block, models.Instruction{Op: &jmp, Labels: []string{e.to}})
+			nameToBlock[split] = block
+			namesInOrder = append(namesInOrder, split)
+
+			from := nameToBlock[e.from]
+			last := from[len(from)-1]
+			labels := make([]string, len(last.Labels))
+			for i, l := range last.Labels {
+				if l == e.to {
+					l = split
+				}
+				labels[i] = l
+			}
+			last.Labels = labels
+			from[len(from)-1] = last
+		} else {
+			nameToBlock[e.from] = utils.InsertBeforeTerminator(nameToBlock[e.from], copies)
+		}
+	}
+
+	instrs := initialize(maybeUndefined(function), fresh)
+	function.Instrs = append(instrs, utils.FlattenBlocks(namesInOrder, nameToBlock)...)
+	return function, nil
+}
+
+type variable struct {
+	name string
+	t    models.Type
+}
+
+// maybeUndefined - the phi destinations that can be undefined, because the
+// phi has an Undefined argument or an argument that's one of these, and that
+// another phi copies from, in program order
+func maybeUndefined(function models.Function) []variable {
+	undefined := utils.NewSet(Undefined)
+	copied := utils.NewSet()
+	changed := true
+	for changed {
+		changed = false
+		for _, inst := range function.Instrs {
+			if inst.Op == nil || *inst.Op != "phi" || undefined.Contains(*inst.Dest) {
+				continue
+			}
+			copied.Add(inst.Args...)
+			for _, arg := range inst.Args {
+				if undefined.Contains(arg) {
+					undefined.Add(*inst.Dest)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var vars []variable
+	for _, inst := range function.Instrs {
+		if inst.Op != nil && *inst.Op == "phi" && undefined.Contains(*inst.Dest) && copied.Contains(*inst.Dest) {
+			vars = append(vars, variable{name: *inst.Dest, t: *inst.Type})
+		}
+	}
+	return vars
+}
+
+// initialize - instructions giving every variable in vars a value of its
+// type. Pointers get an allocation that's freed straight away, there's no
+// null pointer.
+func initialize(vars []variable, fresh func(string) string) []models.Instruction {
+	var out []models.Instruction
+	constant := func(dest string, t models.Type, value models.Value) {
+		op := "const"
+		out = append(out, models.Instruction{Dest: &dest, Op: &op, Type: &t, Value: &value})
+	}
+
+	one := ""
+	for _, v := range vars {
+		switch {
+		case v.t.Parameterized != nil:
+			if one == "" {
+				one = fresh("one")
+				f := 1.0
+				constant(one, primitive("int"), models.Value{Float: &f})
+			}
+			dest, alloc, free := v.name, "alloc", "free"
+			t := v.t
+			out = append(out,
+				models.Instruction{Dest: &dest, Op: &alloc, Type: &t, Args: []string{one}},
+				models.Instruction{Op: &free, Args: []string{v.name}})
+		case v.t.Primitive != nil && *v.t.Primitive == "bool":
+			b := false
+			constant(v.name, v.t, models.Value{Bool: &b})
+		default:
+			f := 0.0
+			constant(v.name, v.t, models.Value{Float: &f})
+		}
+	}
+	return out
+}
+
+func primitive(name string) models.Type {
+	return models.Type{Primitive: &name}
+}
+
+func hasPhi(function models.Function) bool {
+	for _, inst := range function.Instrs {
+		if inst.Op != nil && *inst.Op == "phi" {
+			return true
+		}
+	}
+	return false
+}
+
+// sequentialize orders a set of parallel copies so that executing them one
+// after the other has the same effect as executing them all at once. No copy
+// may overwrite a variable another pending copy still needs to read. When
+// every pending copy is blocked there is a cycle, e.g. a swap, which is broken
+// by saving one of the destinations in a temporary.
+func sequentialize(copies []copyInst, fresh func(string) string) []models.Instruction {
+	var pending []copyInst
+	for _, c := range copies {
+		if c.dest != c.src {
+			pending = append(pending, c)
+		}
+	}
+
+	var out []models.Instruction
+	emit := func(dest, src string, t models.Type) {
+		id := "id"
+		out = append(out, models.Instruction{
+			Args: []string{src},
+			Dest: &dest,
+			Op:   &id,
+			Type: &t,
+		})
+	}
+	isSource := func(v string) bool {
+		for _, c := range pending {
+			if c.src == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	for len(pending) != 0 {
+		ready := -1
+		for i, c := range pending {
+			if !isSource(c.dest) {
+				ready = i
+				break
+			}
+		}
+		if ready >= 0 {
+			c := pending[ready]
+			emit(c.dest, c.src, c.t)
+			pending = append(pending[:ready], pending[ready+1:]...)
+			continue
+		}
+
+		// Everything left is part of a cycle. Move the first
+		// destination out of the way and redirect its readers.
+		c := pending[0]
+		tmp := fresh(c.dest + ".tmp")
+		emit(tmp, c.dest, c.t)
+		for i := range pending {
+			if pending[i].src == c.dest {
+				pending[i].src = tmp
+			}
+		}
+	}
+	return out
+}
+
+// newNamer returns a function that makes up variable and label names that
+// are not already used in function.
+func newNamer(function models.Function) func(string) string {
+	used := utils.NewSet()
+	for _, arg := range function.Args {
+		used.Add(arg.Name)
+	}
+	for _, inst := range function.Instrs {
+		used.Add(inst.Args...)
+		if inst.Dest != nil {
+			used.Add(*inst.Dest)
+		}
+		if inst.Label != nil {
+			used.Add(*inst.Label)
+		}
+	}
+	return func(prefix string) string {
+		for i := 0; ; i++ {
+			name := fmt.Sprintf("%s.%d", prefix, i)
+			if !used.Contains(name) {
+				used.Add(name)
+				return name
+			}
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package ssa
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/dominators"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/text"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// ErrNotSSA is returned by Check when a function breaks SSA form.
+var ErrNotSSA = errors.New("not in SSA form")
+
+// argIndex is the index given to the definitions of function arguments,
+// they come before the first instruction of the entry block.
+const argIndex = -1
+
+// Violation is a way the instruction at Index in Block breaks SSA form.
+type Violation struct {
+	Block       string
+	Index       int
+	Instruction models.Instruction
+	Message     string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", v.Block, v.Index, text.Instruction(v.Instruction), v.Message)
+}
+
+// Check is Verify for use as an assertion after a transform, only the first
+// violation is returned.
+func Check(function models.Function) error {
+	violations, err := Verify(function)
+	if err != nil {
+		return err
+	}
+	if len(violations) != 0 {
+		return fmt.Errorf("%w: %s", ErrNotSSA, violations[0])
+	}
+	return nil
+}
+
+// Verify finds everything that keeps function from being in SSA form:
+//   - a variable assigned more than once
+//   - a use that isn't dominated by the variable's definition, for a phi the
+//     definition has to dominate the end of the predecessor instead
+//   - a phi after the start of its block or whose labels aren't exactly the
+//     predecessors of its block
+//   - a use, outside of a phi, of a phi result that can be undefined because
+//     a phi along the way was given Undefined
+//
+// Blocks that can't be reached from the entry are only checked for the first
+// and third, dominance means nothing there.
+func Verify(function models.Function) ([]Violation, error) {
+	namesInOrder, nameToBlock := utils.BasicBlocks(function)
+	if len(namesInOrder) == 0 {
+		return nil, nil
+	}
+	cfg, err := utils.CFG(namesInOrder, nameToBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	v := verifier{
+		namesInOrder: namesInOrder,
+		nameToBlock:  nameToBlock,
+		cfg:          cfg,
+		dom:          dominators.Dominators(namesInOrder, cfg),
+		varToDef:     make(map[string]utils.Site),
+	}
+	v.definitions(function)
+	v.maybeUndefined()
+	for _, name := range namesInOrder {
+		v.phis(name)
+		for i, inst := range nameToBlock[name] {
+			v.uses(name, i, inst)
+		}
+	}
+
+	// Report in program order rather than by kind of violation
+	blockToIndex := make(map[string]int)
+	for i, name := range namesInOrder {
+		blockToIndex[name] = i
+	}
+	sort.SliceStable(v.violations, func(i, j int) bool {
+		a, b := v.violations[i], v.violations[j]
+		if a.Block != b.Block {
+			return blockToIndex[a.Block] < blockToIndex[b.Block]
+		}
+		return a.Index < b.Index
+	})
+	return v.violations, nil
+}
+
+type verifier struct {
+	namesInOrder []string
+	nameToBlock  map[string][]models.Instruction
+	cfg          utils.Digraph
+	dom          map[string]utils.Set
+	varToDef     map[string]utils.Site
+	// undefined has the phi results that can be undefined in each block
+	undefined  map[string]utils.Set
+	violations []Violation
+}
+
+func (v *verifier) report(block string, index int, format string, a ...interface{}) {
+	v.violations = append(v.violations, Violation{
+		Block:       block,
+		Index:       index,
+		Instruction: v.nameToBlock[block][index],
+		Message:     fmt.Sprintf(format, a...),
+	})
+}
+
+// reachable - whether name can be reached from the entry, an unreachable
+// block is only dominated by itself
+func (v *verifier) reachable(name string) bool {
+	return v.dom[name].Contains(v.namesInOrder[0])
+}
+
+func (v *verifier) definitions(function models.Function) {
+	entry := v.namesInOrder[0]
+	for _, arg := range function.Args {
+		v.varToDef[arg.Name] = utils.Site{Block: entry, Index: argIndex}
+	}
+	for _, name := range v.namesInOrder {
+		for i, inst := range v.nameToBlock[name] {
+			if inst.Dest == nil {
+				continue
+			}
+			if d, ok := v.varToDef[*inst.Dest]; ok {
+				if d.Index == argIndex {
+					v.report(name, i, "%s is an argument and can't be assigned", *inst.Dest)
+				} else {
+					v.report(name, i, "%s is already assigned at %s:%d", *inst.Dest, d.Block, d.Index)
+				}
+				continue
+			}
+			v.varToDef[*inst.Dest] = utils.Site{Block: name, Index: i}
+		}
+	}
+}
+
+// phis checks that the phis of block name come first and have an argument
+// for each of its predecessors.
+func (v *verifier) phis(name string) {
+	preds := utils.Predecessors(v.cfg, name)
+	started := false
+	for i, inst := range v.nameToBlock[name] {
+		if inst.Op == nil {
+			continue
+		}
+		if *inst.Op != "phi" {
+			started = true
+			continue
+		}
+		if started {
+			v.report(name, i, "phi after the start of the block")
+		}
+		if len(inst.Args) != len(inst.Labels) {
+			v.report(name, i, "phi has %d arguments and %d labels", len(inst.Args), len(inst.Labels))
+		}
+
+		seen := utils.NewSet()
+		for _, label := range inst.Labels {
+			if seen.Contains(label) {
+				v.report(name, i, "more than one argument for .%s", label)
+			}
+			seen.Add(label)
+			if !utils.Contains(preds, label) {
+				v.report(name, i, ".%s isn't a predecessor", label)
+			}
+		}
+		for _, pred := range preds {
+			if !seen.Contains(pred) {
+				v.report(name, i, "no argument for predecessor .%s", pred)
+			}
+		}
+	}
+}
+
+// uses checks that every argument of the instruction at index in block is
+// defined before it's used.
+func (v *verifier) uses(block string, index int, inst models.Instruction) {
+	if inst.Op == nil {
+		return
+	}
+	if *inst.Op == "phi" {
+		preds := utils.Predecessors(v.cfg, block)
+		for i, arg := range inst.Args {
+			if i >= len(inst.Labels) {
+				break
+			}
+			// Labels that aren't predecessors were reported by phis
+			pred := inst.Labels[i]
+			if !utils.Contains(preds, pred) || !v.reachable(pred) {
+				continue
+			}
+			if arg == Undefined {
+				continue
+			}
+			// The argument is read at the end of pred
+			v.dominated(block, index, arg, utils.Site{Block: pred, Index: len(v.nameToBlock[pred])})
+		}
+		return
+	}
+
+	if !v.reachable(block) {
+		return
+	}
+	seen := utils.NewSet()
+	for _, arg := range inst.Args {
+		if seen.Contains(arg) {
+			continue
+		}
+		seen.Add(arg)
+		if arg == Undefined {
+			v.report(block, index, "%s outside of a phi", Undefined)
+			continue
+		}
+		if v.undefined[block].Contains(arg) {
+			v.report(block, index, "%s can be undefined", arg)
+		}
+		v.dominated(block, index, arg, utils.Site{Block: block, Index: index})
+	}
+}
+
+// dominated reports the instruction at index in block if the definition of
+// arg doesn't dominate use.
+func (v *verifier) dominated(block string, index int, arg string, use utils.Site) {
+	def, ok := v.varToDef[arg]
+	switch {
+	case !ok:
+		v.report(block, index, "%s is never assigned", arg)
+	case def.Block == use.Block:
+		if def.Index >= use.Index {
+			v.report(block, index, "%s is used before it's assigned", arg)
+		}
+	case !v.dom[use.Block].Contains(def.Block):
+		v.report(block, index, "%s is assigned in %s which doesn't dominate %s", arg, def.Block, use.Block)
+	}
+}
+
+// maybeUndefined finds the phi results that can be undefined in each block
+// reachable from the entry. A phi's result can be undefined when it's given
+// Undefined, or a phi result that can be undefined, by a predecessor. Only
+// phis assign these so the set is the same all through a block, after its
+// phis.
+func (v *verifier) maybeUndefined() {
+	v.undefined = make(map[string]utils.Set)
+	for _, name := range v.namesInOrder {
+		v.undefined[name] = utils.NewSet()
+	}
+
+	changed := true
+	for changed {
+		changed = false
+		for _, name := range v.namesInOrder {
+			if !v.reachable(name) {
+				continue
+			}
+			phis := utils.NewSet()
+			undefined := utils.NewSet()
+			for _, inst := range v.nameToBlock[name] {
+				if inst.Op == nil || *inst.Op != "phi" || inst.Dest == nil {
+					continue
+				}
+				phis.Add(*inst.Dest)
+				for i, arg := range inst.Args {
+					if i >= len(inst.Labels) {
+						break
+					}
+					in, ok := v.undefined[inst.Labels[i]]
+					if ok && v.reachable(inst.Labels[i]) && (arg == Undefined || in.Contains(arg)) {
+						undefined.Add(*inst.Dest)
+					}
+				}
+			}
+			// Whatever can be undefined at the end of a predecessor
+			// still can be, except the phis here which were just
+			// assigned
+			for _, pred := range utils.Predecessors(v.cfg, name) {
+				for variable := range v.undefined[pred] {
+					if !phis.Contains(variable) {
+						undefined.Add(variable)
+					}
+				}
+			}
+			if len(undefined) != len(v.undefined[name]) {
+				v.undefined[name] = undefined
+				changed = true
+			}
+		}
+	}
+}
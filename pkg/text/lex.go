@@ -0,0 +1,151 @@
+package text
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	identToken
+	funcToken  // @name
+	labelToken // .name
+	numberToken
+	punctToken // one of ( ) { } < > : ; = ,
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case eofToken:
+		return "end of input"
+	case identToken:
+		return "identifier"
+	case funcToken:
+		return "function name"
+	case labelToken:
+		return "label"
+	case numberToken:
+		return "number"
+	case punctToken:
+		return "punctuation"
+	}
+	return "unknown"
+}
+
+type token struct {
+	kind tokenKind
+	// For function names and labels this doesn't include the leading @
+	// or . since that's how they are stored in the JSON.
+	text string
+	line int
+	col  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case eofToken:
+		return t.kind.String()
+	case funcToken:
+		return fmt.Sprintf("%q", "@"+t.text)
+	case labelToken:
+		return fmt.Sprintf("%q", "."+t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '%' || unicode.IsLetter(r)
+}
+
+func isIdentRest(r rune) bool {
+	return isIdentStart(r) || r == '.' || unicode.IsDigit(r)
+}
+
+// lex breaks src into tokens. Comments (# to the end of the line) and
+// whitespace are dropped. The returned slice always ends with an eofToken.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	line, col := 1, 1
+	i := 0
+
+	advance := func() {
+		if runes[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+		i++
+	}
+	peek := func(offset int) rune {
+		if i+offset < len(runes) {
+			return runes[i+offset]
+		}
+		return 0
+	}
+	takeWhile := func(pred func(rune) bool) string {
+		var sb strings.Builder
+		for i < len(runes) && pred(runes[i]) {
+			sb.WriteRune(runes[i])
+			advance()
+		}
+		return sb.String()
+	}
+	isNumberRest := func(r rune) bool {
+		return unicode.IsDigit(r) || r == '.' || r == 'e' || r == 'E'
+	}
+
+	for i < len(runes) {
+		r := runes[i]
+		startLine, startCol := line, col
+		emit := func(kind tokenKind, text string) {
+			tokens = append(tokens, token{kind: kind, text: text, line: startLine, col: startCol})
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			advance()
+		case r == '#':
+			takeWhile(func(r rune) bool { return r != '\n' })
+		case strings.ContainsRune("(){}<>:;=,", r):
+			advance()
+			emit(punctToken, string(r))
+		case r == '@':
+			advance()
+			name := takeWhile(isIdentRest)
+			if name == "" {
+				return nil, fmt.Errorf("%d:%d: expected function name after @", startLine, startCol)
+			}
+			emit(funcToken, name)
+		case r == '.' && isIdentStart(peek(1)):
+			advance()
+			emit(labelToken, takeWhile(isIdentRest))
+		case unicode.IsDigit(r) || r == '.' || ((r == '-' || r == '+') && (unicode.IsDigit(peek(1)) || peek(1) == '.')):
+			var sb strings.Builder
+			sb.WriteRune(r)
+			advance()
+			for i < len(runes) {
+				// A sign is only part of a number directly after
+				// an exponent.
+				prev := runes[i-1]
+				if isNumberRest(runes[i]) || ((runes[i] == '-' || runes[i] == '+') && (prev == 'e' || prev == 'E')) {
+					sb.WriteRune(runes[i])
+					advance()
+				} else {
+					break
+				}
+			}
+			emit(numberToken, sb.String())
+		case isIdentStart(r):
+			emit(identToken, takeWhile(isIdentRest))
+		default:
+			return nil, fmt.Errorf("%d:%d: unexpected character %q", startLine, startCol, r)
+		}
+	}
+	tokens = append(tokens, token{kind: eofToken, line: line, col: col})
+	return tokens, nil
+}
@@ -0,0 +1,256 @@
+// Package text converts between the textual form of Bril, the one used by the
+// .bril files in test/, and models.Program. It replaces the bril2json and
+// bril2txt tools from the Bril repository.
+package text
+
+import (
+	"fmt"
+	"strconv"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+)
+
+// Parse parses a textual Bril program.
+func Parse(src string) (models.Program, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return models.Program{}, err
+	}
+	p := &parser{tokens: tokens}
+
+	prog := models.Program{Functions: []models.Function{}}
+	for p.peek().kind != eofToken {
+		function, err := p.function()
+		if err != nil {
+			return models.Program{}, err
+		}
+		prog.Functions = append(prog.Functions, function)
+	}
+	return prog, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != eofToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == punctToken && t.text == s
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%d:%d: %s", t.line, t.col, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expectPunct(s string) error {
+	t := p.next()
+	if t.kind != punctToken || t.text != s {
+		return p.errorf(t, "expected %q, found %s", s, t)
+	}
+	return nil
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, p.errorf(t, "expected %s, found %s", kind, t)
+	}
+	return t, nil
+}
+
+// function := FUNC ['(' [arg {',' arg}] ')'] [':' type] '{' {instr} '}'
+func (p *parser) function() (models.Function, error) {
+	name, err := p.expect(funcToken)
+	if err != nil {
+		return models.Function{}, err
+	}
+	function := models.Function{Name: name.text, Instrs: []models.Instruction{}}
+
+	if p.isPunct("(") {
+		p.next()
+		for !p.isPunct(")") {
+			if len(function.Args) > 0 {
+				if err := p.expectPunct(","); err != nil {
+					return models.Function{}, err
+				}
+			}
+			argName, err := p.expect(identToken)
+			if err != nil {
+				return models.Function{}, err
+			}
+			if err := p.expectPunct(":"); err != nil {
+				return models.Function{}, err
+			}
+			t, err := p.typ()
+			if err != nil {
+				return models.Function{}, err
+			}
+			function.Args = append(function.Args, models.Args{Name: argName.text, Type: &t})
+		}
+		p.next()
+	}
+
+	if p.isPunct(":") {
+		p.next()
+		t, err := p.typ()
+		if err != nil {
+			return models.Function{}, err
+		}
+		function.Type = &t
+	}
+
+	if err := p.expectPunct("{"); err != nil {
+		return models.Function{}, err
+	}
+	for !p.isPunct("}") {
+		inst, err := p.instruction()
+		if err != nil {
+			return models.Function{}, err
+		}
+		function.Instrs = append(function.Instrs, inst)
+	}
+	p.next()
+	return function, nil
+}
+
+// type := IDENT ['<' type '>']
+func (p *parser) typ() (models.Type, error) {
+	name, err := p.expect(identToken)
+	if err != nil {
+		return models.Type{}, err
+	}
+	if !p.isPunct("<") {
+		return models.Type{Primitive: &name.text}, nil
+	}
+	p.next()
+	inner, err := p.typ()
+	if err != nil {
+		return models.Type{}, err
+	}
+	if err := p.expectPunct(">"); err != nil {
+		return models.Type{}, err
+	}
+	return models.Type{Parameterized: &models.ParameterizedType{Parameter: name.text, Type: inner}}, nil
+}
+
+// instr := LABEL ':' | IDENT [':' type] '=' op ';' | op ';'
+func (p *parser) instruction() (models.Instruction, error) {
+	t := p.peek()
+	if t.kind == labelToken {
+		p.next()
+		if err := p.expectPunct(":"); err != nil {
+			return models.Instruction{}, err
+		}
+		label := t.text
+		return models.Instruction{Label: &label}, nil
+	}
+
+	next := p.peekAt(1)
+	if t.kind == identToken && next.kind == punctToken && (next.text == ":" || next.text == "=") {
+		return p.valueOperation()
+	}
+
+	inst, err := p.operation()
+	if err != nil {
+		return models.Instruction{}, err
+	}
+	return inst, p.expectPunct(";")
+}
+
+func (p *parser) valueOperation() (models.Instruction, error) {
+	dest := p.next().text
+	var typ *models.Type
+	if p.isPunct(":") {
+		p.next()
+		t, err := p.typ()
+		if err != nil {
+			return models.Instruction{}, err
+		}
+		typ = &t
+	}
+	if err := p.expectPunct("="); err != nil {
+		return models.Instruction{}, err
+	}
+
+	var inst models.Instruction
+	var err error
+	if t := p.peek(); t.kind == identToken && t.text == "const" {
+		p.next()
+		inst, err = p.constant()
+	} else {
+		inst, err = p.operation()
+	}
+	if err != nil {
+		return models.Instruction{}, err
+	}
+	inst.Dest = &dest
+	inst.Type = typ
+	return inst, p.expectPunct(";")
+}
+
+// constant parses the literal after "const". The Bril JSON doesn't
+// distinguish ints and floats, they're both just JSON numbers, so all that
+// matters here is numbers vs. booleans.
+func (p *parser) constant() (models.Instruction, error) {
+	op := "const"
+	t := p.next()
+	var value models.Value
+	switch {
+	case t.kind == identToken && (t.text == "true" || t.text == "false"):
+		b := t.text == "true"
+		value.Bool = &b
+	case t.kind == numberToken:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return models.Instruction{}, p.errorf(t, "malformed number %s", t)
+		}
+		value.Float = &f
+	default:
+		return models.Instruction{}, p.errorf(t, "expected literal, found %s", t)
+	}
+	return models.Instruction{Op: &op, Value: &value}, nil
+}
+
+// op := IDENT {FUNC | LABEL | IDENT}
+func (p *parser) operation() (models.Instruction, error) {
+	opToken, err := p.expect(identToken)
+	if err != nil {
+		return models.Instruction{}, err
+	}
+	op := opToken.text
+	inst := models.Instruction{Op: &op}
+	for {
+		t := p.peek()
+		switch t.kind {
+		case identToken:
+			inst.Args = append(inst.Args, t.text)
+		case funcToken:
+			inst.Funcs = append(inst.Funcs, t.text)
+		case labelToken:
+			inst.Labels = append(inst.Labels, t.text)
+		default:
+			return inst, nil
+		}
+		p.next()
+	}
+}
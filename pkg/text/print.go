@@ -0,0 +1,118 @@
+package text
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+)
+
+// Print writes prog in the same format bril2txt uses.
+func Print(w io.Writer, prog models.Program) error {
+	for _, function := range prog.Functions {
+		if _, err := io.WriteString(w, Function(function)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function formats a single function including the trailing newline.
+func Function(function models.Function) string {
+	var sb strings.Builder
+	sb.WriteString("@" + function.Name)
+	if len(function.Args) != 0 {
+		var args []string
+		for _, arg := range function.Args {
+			args = append(args, fmt.Sprintf("%s: %s", arg.Name, arg.Type))
+		}
+		sb.WriteString("(" + strings.Join(args, ", ") + ")")
+	}
+	if function.Type != nil {
+		sb.WriteString(": " + function.Type.String())
+	}
+	sb.WriteString(" {\n")
+	for _, inst := range function.Instrs {
+		if inst.Op == nil && inst.Label != nil {
+			sb.WriteString("." + *inst.Label + ":\n")
+		} else {
+			sb.WriteString("  " + Instruction(inst) + ";\n")
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Instruction formats an instruction without indentation or the trailing
+// semicolon.
+func Instruction(inst models.Instruction) string {
+	if inst.Op == nil {
+		if inst.Label != nil {
+			return "." + *inst.Label + ":"
+		}
+		return ""
+	}
+
+	var rhs string
+	if *inst.Op == "const" {
+		rhs = "const " + literal(inst)
+	} else {
+		parts := []string{*inst.Op}
+		for _, f := range inst.Funcs {
+			parts = append(parts, "@"+f)
+		}
+		parts = append(parts, inst.Args...)
+		for _, l := range inst.Labels {
+			parts = append(parts, "."+l)
+		}
+		rhs = strings.Join(parts, " ")
+	}
+
+	if inst.Dest == nil {
+		return rhs
+	}
+	if inst.Type == nil {
+		return fmt.Sprintf("%s = %s", *inst.Dest, rhs)
+	}
+	return fmt.Sprintf("%s: %s = %s", *inst.Dest, inst.Type, rhs)
+}
+
+func literal(inst models.Instruction) string {
+	if inst.Value == nil {
+		return ""
+	}
+	if inst.Value.Bool != nil {
+		return strconv.FormatBool(*inst.Value.Bool)
+	}
+	if inst.Value.Float == nil {
+		return ""
+	}
+	f := *inst.Value.Float
+	if inst.Type != nil && inst.Type.Primitive != nil && *inst.Type.Primitive == "float" {
+		return formatFloat(f)
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return formatFloat(f)
+}
+
+// formatFloat mimics the way Python prints floats, which is what bril2txt
+// uses. Python always includes a decimal point and switches to scientific
+// notation for very large and very small numbers.
+func formatFloat(f float64) string {
+	abs := math.Abs(f)
+	if math.IsInf(f, 0) || math.IsNaN(f) || abs == 0 || (abs >= 1e-4 && abs < 1e16) {
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".IN") {
+			s += ".0"
+		}
+		return s
+	}
+	// Conveniently Go and Python agree on how exponents look, at least
+	// a two digit exponent with a sign.
+	return strconv.FormatFloat(f, 'e', -1, 64)
+}
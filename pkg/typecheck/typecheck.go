@@ -0,0 +1,302 @@
+// Package typecheck checks that every instruction is given arguments of the
+// types it needs and produces the type its destination is declared with.
+//
+// A variable can be redefined with a different type, so the type of an
+// argument is found from the definitions that reach it rather than from the
+// variable's name. When definitions of more than one type reach a use there's
+// no single type to check against and that is reported instead.
+package typecheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/chains"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/ops"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/text"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/utils"
+)
+
+// Error is a type error in the instruction at Index in Block.
+type Error struct {
+	Block       string
+	Index       int
+	Instruction models.Instruction
+	Message     string
+}
+
+func (e Error) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", e.Block, e.Index, text.Instruction(e.Instruction), e.Message)
+}
+
+// signature is the types an op takes and gives
+type signature struct {
+	arg, result string
+}
+
+var signatures = map[string]signature{
+	"add": {"int", "int"},
+	"sub": {"int", "int"},
+	"mul": {"int", "int"},
+	"div": {"int", "int"},
+	"eq":  {"int", "bool"},
+	"lt":  {"int", "bool"},
+	"gt":  {"int", "bool"},
+	"le":  {"int", "bool"},
+	"ge":  {"int", "bool"},
+
+	"not": {"bool", "bool"},
+	"and": {"bool", "bool"},
+	"or":  {"bool", "bool"},
+
+	"fadd": {"float", "float"},
+	"fsub": {"float", "float"},
+	"fmul": {"float", "float"},
+	"fdiv": {"float", "float"},
+	"feq":  {"float", "bool"},
+	"flt":  {"float", "bool"},
+	"fgt":  {"float", "bool"},
+	"fle":  {"float", "bool"},
+	"fge":  {"float", "bool"},
+}
+
+// Check finds the type errors in function, prog is needed for the types of
+// the functions it calls. Ops and arities that aren't known are left for
+// validate, only the types of the arguments that are there are checked.
+func Check(prog models.Program, function models.Function) ([]Error, error) {
+	c, err := chains.Build(function)
+	if err != nil {
+		return nil, err
+	}
+
+	k := checker{
+		function:       function,
+		chains:         c,
+		nameToFunction: make(map[string]models.Function),
+		defined:        utils.NewSet(),
+	}
+	for _, f := range prog.Functions {
+		k.nameToFunction[f.Name] = f
+	}
+	for _, arg := range function.Args {
+		k.defined.Add(arg.Name)
+	}
+	for _, inst := range function.Instrs {
+		if inst.Dest != nil {
+			k.defined.Add(*inst.Dest)
+		}
+	}
+
+	var namesInOrder []string
+	namesInOrder, k.nameToBlock = utils.BasicBlocks(function)
+	for _, name := range namesInOrder {
+		for i, inst := range k.nameToBlock[name] {
+			k.check(name, i, inst)
+		}
+	}
+	return k.errors, nil
+}
+
+type checker struct {
+	function       models.Function
+	chains         chains.Chains
+	nameToBlock    map[string][]models.Instruction
+	nameToFunction map[string]models.Function
+	// defined has every variable that's assigned anywhere in the function
+	defined utils.Set
+	errors  []Error
+
+	// the instruction being checked
+	block string
+	index int
+	inst  models.Instruction
+}
+
+func (k *checker) report(format string, a ...interface{}) {
+	k.errors = append(k.errors, Error{
+		Block:       k.block,
+		Index:       k.index,
+		Instruction: k.inst,
+		Message:     fmt.Sprintf(format, a...),
+	})
+}
+
+// typeOf - the type of the definition d
+func (k *checker) typeOf(d chains.Def) *models.Type {
+	if d.Index == chains.ArgIndex {
+		for _, arg := range k.function.Args {
+			if arg.Name == d.Var {
+				return arg.Type
+			}
+		}
+		return nil
+	}
+	return k.nameToBlock[d.Block][d.Index].Type
+}
+
+// argTypes finds the type of every argument of the current instruction from
+// the definitions that reach it. Arguments without a single type are missing,
+// the reason why is reported here so it's only reported once.
+func (k *checker) argTypes() map[string]*models.Type {
+	types := make(map[string]*models.Type)
+	for _, arg := range k.inst.Args {
+		if _, ok := types[arg]; ok {
+			continue
+		}
+		defs := k.chains.UseDef[chains.Use{Block: k.block, Index: k.index, Var: arg}]
+
+		nameToType := make(map[string]*models.Type)
+		for _, d := range defs {
+			if t := k.typeOf(d); t != nil {
+				nameToType[t.String()] = t
+			}
+		}
+		switch len(nameToType) {
+		case 0:
+			// A phi names an undefined variable for the paths where
+			// there's no value, that's not a mistake
+			if !k.defined.Contains(arg) && *k.inst.Op != "phi" {
+				k.report("%s is undefined", arg)
+			}
+		case 1:
+			for _, t := range nameToType {
+				types[arg] = t
+			}
+		default:
+			var names []string
+			for name := range nameToType {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			k.report("%s may be %s", arg, strings.Join(names, " or "))
+		}
+		if _, ok := types[arg]; !ok {
+			types[arg] = nil
+		}
+	}
+	return types
+}
+
+func (k *checker) check(block string, index int, inst models.Instruction) {
+	if inst.Op == nil {
+		return
+	}
+	k.block, k.index, k.inst = block, index, inst
+	types := k.argTypes()
+
+	// want reports arg if its type is known and isn't t
+	want := func(arg string, t string) {
+		if types[arg] != nil && types[arg].String() != t {
+			k.report("%s is %s, not %s", arg, types[arg], t)
+		}
+	}
+	// gives reports the destination if it isn't declared with t
+	gives := func(what string, t string) {
+		if inst.Type != nil && inst.Type.String() != t {
+			k.report("%s gives %s, not %s", what, t, inst.Type)
+		}
+	}
+	// pointer - the type arg points to, reporting it if it isn't a pointer
+	pointer := func(arg string) *models.Type {
+		t := types[arg]
+		if t == nil {
+			return nil
+		}
+		if p := pointee(t); p != nil {
+			return p
+		}
+		k.report("%s is %s, not a pointer", arg, t)
+		return nil
+	}
+
+	if s, ok := signatures[*inst.Op]; ok {
+		for _, arg := range inst.Args {
+			want(arg, s.arg)
+		}
+		gives(*inst.Op, s.result)
+		return
+	}
+
+	switch *inst.Op {
+	case "const":
+		if inst.Type != nil && inst.Value != nil {
+			if _, err := ops.Literal(inst); err != nil {
+				k.report("%s", err)
+			}
+		}
+	case "id":
+		if len(inst.Args) == 1 && types[inst.Args[0]] != nil {
+			gives("id", types[inst.Args[0]].String())
+		}
+	case "br":
+		if len(inst.Args) == 1 {
+			want(inst.Args[0], "bool")
+		}
+	case "ret":
+		if len(inst.Args) == 1 && k.function.Type != nil {
+			want(inst.Args[0], k.function.Type.String())
+		}
+	case "call":
+		if len(inst.Funcs) != 1 {
+			return
+		}
+		callee, ok := k.nameToFunction[inst.Funcs[0]]
+		if !ok {
+			return
+		}
+		for i, arg := range inst.Args {
+			if i < len(callee.Args) && callee.Args[i].Type != nil {
+				want(arg, callee.Args[i].Type.String())
+			}
+		}
+		if callee.Type != nil {
+			gives("@"+callee.Name, callee.Type.String())
+		}
+	case "phi":
+		if inst.Type != nil {
+			for _, arg := range inst.Args {
+				want(arg, inst.Type.String())
+			}
+		}
+	case "alloc":
+		if len(inst.Args) == 1 {
+			want(inst.Args[0], "int")
+		}
+		if inst.Type != nil && pointee(inst.Type) == nil {
+			k.report("alloc gives a pointer, not %s", inst.Type)
+		}
+	case "free":
+		if len(inst.Args) == 1 {
+			pointer(inst.Args[0])
+		}
+	case "load":
+		if len(inst.Args) == 1 {
+			if p := pointer(inst.Args[0]); p != nil {
+				gives("load", p.String())
+			}
+		}
+	case "store":
+		if len(inst.Args) == 2 {
+			if p := pointer(inst.Args[0]); p != nil {
+				want(inst.Args[1], p.String())
+			}
+		}
+	case "ptradd":
+		if len(inst.Args) == 2 {
+			if p := pointer(inst.Args[0]); p != nil {
+				gives("ptradd", types[inst.Args[0]].String())
+			}
+			want(inst.Args[1], "int")
+		}
+	}
+}
+
+// pointee - the type t points to, nil if t isn't a pointer
+func pointee(t *models.Type) *models.Type {
+	if t.Parameterized == nil || t.Parameterized.Parameter != "ptr" {
+		return nil
+	}
+	return &t.Parameterized.Type
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// Universe numbers every name a BitSet can hold. Sets from the same universe
+// can be combined, mixing universes is a programming error.
+type Universe struct {
+	names []string
+	index map[string]int
+}
+
+// NewUniverse - names are sorted so a BitSet can be printed in order without
+// sorting it.
+func NewUniverse(names ...string) *Universe {
+	u := &Universe{index: make(map[string]int)}
+	for _, name := range names {
+		if _, ok := u.index[name]; !ok {
+			u.index[name] = -1
+			u.names = append(u.names, name)
+		}
+	}
+	sort.Strings(u.names)
+	for i, name := range u.names {
+		u.index[name] = i
+	}
+	return u
+}
+
+func (u *Universe) Len() int {
+	return len(u.names)
+}
+
+// Empty - a set containing items
+func (u *Universe) Empty(items ...string) BitSet {
+	s := BitSet{u: u, words: make([]uint64, (len(u.names)+63)/64)}
+	s.Add(items...)
+	return s
+}
+
+// Full - a set containing every name in the universe
+func (u *Universe) Full() BitSet {
+	s := u.Empty()
+	for i := range s.words {
+		s.words[i] = ^uint64(0)
+	}
+	if extra := len(u.names) % 64; extra != 0 {
+		s.words[len(s.words)-1] = (1 << extra) - 1
+	}
+	return s
+}
+
+// BitSet is a set of names from a Universe stored as one bit per name. The
+// operations that return a BitSet never modify their arguments.
+type BitSet struct {
+	u     *Universe
+	words []uint64
+}
+
+// Add panics if an item isn't in the universe
+func (s BitSet) Add(items ...string) {
+	for _, item := range items {
+		i, ok := s.u.index[item]
+		if !ok {
+			panic("assertion error: " + item + " is not in the universe")
+		}
+		s.words[i/64] |= 1 << (i % 64)
+	}
+}
+
+func (s BitSet) Contains(item string) bool {
+	i, ok := s.u.index[item]
+	return ok && s.words[i/64]&(1<<(i%64)) != 0
+}
+
+func (s BitSet) Remove(item string) {
+	if i, ok := s.u.index[item]; ok {
+		s.words[i/64] &^= 1 << (i % 64)
+	}
+}
+
+func (s BitSet) Copy() BitSet {
+	out := BitSet{u: s.u, words: make([]uint64, len(s.words))}
+	copy(out.words, s.words)
+	return out
+}
+
+func (s BitSet) Union(o BitSet) BitSet {
+	out := s.Copy()
+	for i, w := range o.words {
+		out.words[i] |= w
+	}
+	return out
+}
+
+func (s BitSet) Intersect(o BitSet) BitSet {
+	out := s.Copy()
+	for i, w := range o.words {
+		out.words[i] &= w
+	}
+	return out
+}
+
+func (s BitSet) Sub(o BitSet) BitSet {
+	out := s.Copy()
+	for i, w := range o.words {
+		out.words[i] &^= w
+	}
+	return out
+}
+
+func (s BitSet) Equal(o BitSet) bool {
+	for i, w := range s.words {
+		if o.words[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+func (s BitSet) Len() int {
+	n := 0
+	for _, w := range s.words {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Items - the names in the set, sorted
+func (s BitSet) Items() []string {
+	var items []string
+	for i, w := range s.words {
+		for w != 0 {
+			b := bits.TrailingZeros64(w)
+			items = append(items, s.u.names[i*64+b])
+			w &^= 1 << b
+		}
+	}
+	return items
+}
+
+// Set - the same names as a Set
+func (s BitSet) Set() Set {
+	return NewSet(s.Items()...)
+}
+
+// String is the same as Set.String
+func (s BitSet) String() string {
+	items := s.Items()
+	if len(items) == 0 {
+		return "∅"
+	}
+	return strings.Join(items, ", ")
+}
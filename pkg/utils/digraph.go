@@ -10,7 +10,7 @@ type Digraph map[string][]string
 func Predecessors(cfg Digraph, name string) []string {
 	var predecessors []string
 	for n, to := range cfg {
-		if contains(to, name) {
+		if Contains(to, name) {
 			predecessors = append(predecessors, n)
 		}
 	}
@@ -54,13 +54,50 @@ func WalkUp(cfg Digraph, start string, walk func(name string) bool) {
 	bfs(cfg, start, Up, walk)
 }
 
-func OutputDot(namesInOrder []string, cfg Digraph) {
+// FunctionGraph is a graph over the blocks of the function Name
+type FunctionGraph struct {
+	Name         string
+	NamesInOrder []string
+	Graph        Digraph
+}
+
+// OutputDot draws each graph as a cluster. Nodes are named after their
+// function and block so blocks with the same name in different functions
+// stay apart.
+func OutputDot(graphs []FunctionGraph) {
 	fmt.Println("digraph G {")
-	for _, name := range namesInOrder {
-		sort.Strings(cfg[name])
-		for _, jumped := range cfg[name] {
-			fmt.Printf("  \"%s\" -> \"%s\";\n", name, jumped)
+	for _, g := range graphs {
+		node := func(name string) string {
+			return fmt.Sprintf("\"%s.%s\"", g.Name, name)
+		}
+		fmt.Printf("  subgraph \"cluster_%s\" {\n", g.Name)
+		fmt.Printf("    label = \"@%s\";\n", g.Name)
+		for _, name := range g.NamesInOrder {
+			fmt.Printf("    %s [label = \"%s\"];\n", node(name), name)
 		}
+		for _, name := range g.NamesInOrder {
+			to := append([]string(nil), g.Graph[name]...)
+			sort.Strings(to)
+			for _, jumped := range to {
+				fmt.Printf("    %s -> %s;\n", node(name), node(jumped))
+			}
+		}
+		fmt.Println("  }")
 	}
 	fmt.Println("}")
 }
+
+// Reverse - cfg with every edge turned around, the edges out of each node
+// are sorted
+func Reverse(cfg Digraph) Digraph {
+	out := make(Digraph)
+	for from, to := range cfg {
+		for _, name := range to {
+			out[name] = append(out[name], from)
+		}
+	}
+	for _, to := range out {
+		sort.Strings(to)
+	}
+	return out
+}
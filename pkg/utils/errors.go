@@ -0,0 +1,18 @@
+package utils
+
+import "errors"
+
+// Errors for programs the utilities can't work with. They are wrapped with
+// the details, check for them with errors.Is.
+var (
+	// ErrMalformedProgram is returned for input that isn't a Bril
+	// program, like invalid JSON or an instruction without an op or a
+	// label
+	ErrMalformedProgram = errors.New("malformed program")
+	// ErrEmptyFunction is returned when a function needs at least one
+	// block
+	ErrEmptyFunction = errors.New("empty function")
+	// ErrMissingLabel is returned when a jump goes to a label that isn't
+	// in the function
+	ErrMissingLabel = errors.New("missing label")
+)
@@ -34,6 +34,18 @@ func (s Set) Remove(item string) {
 	delete(s, item)
 }
 
+func (s Set) Equal(o Set) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for item := range s {
+		if !o.Contains(item) {
+			return false
+		}
+	}
+	return true
+}
+
 func NewSet(items ...string) Set {
 	out := make(Set)
 	out.Add(items...)
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
 
 	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
@@ -21,6 +20,14 @@ func BasicBlocks(function models.Function) (namesInOrder []string, nameToBlock m
 
 	var block []models.Instruction
 
+	// Names made up for blocks without a label can't be the same as one
+	// of the labels
+	labels := NewSet()
+	for _, instruction := range function.Instrs {
+		if instruction.Label != nil {
+			labels.Add(*instruction.Label)
+		}
+	}
 	blockCounter := 1
 	var blockName *string
 
@@ -28,6 +35,10 @@ func BasicBlocks(function models.Function) (namesInOrder []string, nameToBlock m
 		// If there was no blockName from a label for the block give it one
 		if blockName == nil {
 			tmp := fmt.Sprintf("b%d", blockCounter)
+			for labels.Contains(tmp) {
+				blockCounter++
+				tmp = fmt.Sprintf("b%d", blockCounter)
+			}
 			blockName = &tmp
 			blockCounter++
 		}
@@ -44,7 +55,7 @@ func BasicBlocks(function models.Function) (namesInOrder []string, nameToBlock m
 	for _, instruction := range function.Instrs {
 		if instruction.Op != nil {
 			block = append(block, instruction)
-			if contains(terminators[:], *instruction.Op) {
+			if Contains(terminators[:], *instruction.Op) {
 				addBlock()
 			}
 		} else { // we have a label
@@ -61,7 +72,12 @@ func BasicBlocks(function models.Function) (namesInOrder []string, nameToBlock m
 				// it's just a case that came up in the existing
 				// tests. That explanation sort of makes sense
 				// though.
-				temp := "entry1"
+				// Running a function that already has
+				// one of these blocks back through here
+				// (the output of to-ssa for example)
+				// shouldn't add another with the same
+				// name.
+				temp := uniqueLabel(function, "entry")
 				jmp := "jmp"
 				blockName = &temp
 				block = []models.Instruction{{
@@ -82,7 +98,32 @@ func BasicBlocks(function models.Function) (namesInOrder []string, nameToBlock m
 	return namesInOrder, nameToBlock
 }
 
-func contains(strs []string, str string) bool {
+// uniqueLabel returns prefix followed by the first counter, starting at 1,
+// that doesn't collide with a label already in function.
+func uniqueLabel(function models.Function, prefix string) string {
+	labels := NewSet()
+	for _, inst := range function.Instrs {
+		if inst.Label != nil {
+			labels.Add(*inst.Label)
+		}
+	}
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s%d", prefix, i)
+		if !labels.Contains(name) {
+			return name
+		}
+	}
+}
+
+// Site is the location of an instruction, the block it's in and its index in
+// that block.
+type Site struct {
+	Block string
+	Index int
+}
+
+// Contains reports whether str is one of strs.
+func Contains(strs []string, str string) bool {
 	for _, a := range strs {
 		if a == str {
 			return true
@@ -91,8 +132,25 @@ func contains(strs []string, str string) bool {
 	return false
 }
 
-// CFG computes the control flow graph
-func CFG(namesInOrder []string, nameToBlock map[string][]models.Instruction) Digraph {
+// InsertBeforeTerminator returns a copy of block with insts added at its end,
+// but ahead of the jmp, br or ret that ends it if there is one.
+func InsertBeforeTerminator(block []models.Instruction, insts []models.Instruction) []models.Instruction {
+	i := len(block)
+	if i > 0 {
+		if last := block[i-1]; last.Op != nil && Contains(terminators[:], *last.Op) {
+			i--
+		}
+	}
+	out := make([]models.Instruction, 0, len(block)+len(insts))
+	out = append(out, block[:i]...)
+	out = append(out, insts...)
+	out = append(out, block[i:]...)
+	return out
+}
+
+// CFG computes the control flow graph. Every label jumped to has to start a
+// block.
+func CFG(namesInOrder []string, nameToBlock map[string][]models.Instruction) (Digraph, error) {
 	nameToJumpedTo := make(map[string][]string)
 	for i, name := range namesInOrder {
 		block := nameToBlock[name]
@@ -104,7 +162,8 @@ func CFG(namesInOrder []string, nameToBlock map[string][]models.Instruction) Dig
 			}
 		}
 
-		if len(block) == 0 {
+		// A block with nothing but a label falls through like an empty one
+		if len(block) == 0 || block[len(block)-1].Op == nil {
 			proceedingBlock()
 		} else {
 			// If the last instruction is a jmp or a br then the jumped to
@@ -112,6 +171,11 @@ func CFG(namesInOrder []string, nameToBlock map[string][]models.Instruction) Dig
 			lastInst := block[len(block)-1]
 			switch *lastInst.Op {
 			case "jmp", "br":
+				for _, label := range lastInst.Labels {
+					if _, ok := nameToBlock[label]; !ok {
+						return nil, fmt.Errorf("%w: .%s in %s", ErrMissingLabel, label, name)
+					}
+				}
 				jumpedTo = lastInst.Labels
 			case "ret":
 				// Return instructions don't have following instructions.
@@ -127,36 +191,37 @@ func CFG(namesInOrder []string, nameToBlock map[string][]models.Instruction) Dig
 			nameToJumpedTo[name] = jumpedTo
 		}
 	}
-	return nameToJumpedTo
+	return nameToJumpedTo, nil
 }
 
-// ReadProgram reads program from STDIN. All errors are fatal.
-func ReadProgram() models.Program {
-	reader := bufio.NewReader(os.Stdin)
-	var output []rune
-
-	for {
-		input, _, err := reader.ReadRune()
-		if err != nil && err == io.EOF {
-			break
-		}
-		output = append(output, input)
+// ReadProgram reads program from STDIN
+func ReadProgram() (models.Program, error) {
+	input, err := io.ReadAll(bufio.NewReader(os.Stdin))
+	if err != nil {
+		return models.Program{}, err
 	}
 	var prog models.Program
-	err := json.Unmarshal([]byte(string(output)), &prog)
-	if err != nil {
-		log.Fatal(err)
+	if err := json.Unmarshal(input, &prog); err != nil {
+		return models.Program{}, fmt.Errorf("%w: %s", ErrMalformedProgram, err)
+	}
+	for _, function := range prog.Functions {
+		for i, inst := range function.Instrs {
+			if inst.Op == nil && inst.Label == nil {
+				return models.Program{}, fmt.Errorf("%w: instruction %d of @%s has no op or label", ErrMalformedProgram, i, function.Name)
+			}
+		}
 	}
 
-	return prog
+	return prog, nil
 }
 
-func PrintProgram(prog models.Program) {
+func PrintProgram(prog models.Program) error {
 	out, err := json.Marshal(&prog)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	fmt.Println(string(out))
+	return nil
 }
 
 func FlattenBlocks(namesInOrder []string, nameToBlock map[string][]models.Instruction) []models.Instruction {
@@ -167,7 +232,10 @@ func FlattenBlocks(namesInOrder []string, nameToBlock map[string][]models.Instru
 	return out
 }
 
-func AddRet(namesInOrder []string, nameToBlock map[string][]models.Instruction) ([]string, map[string][]models.Instruction) {
+func AddRet(namesInOrder []string, nameToBlock map[string][]models.Instruction) ([]string, map[string][]models.Instruction, error) {
+	if len(namesInOrder) == 0 {
+		return nil, nil, ErrEmptyFunction
+	}
 	out := make(map[string][]models.Instruction)
 	for _, name := range namesInOrder {
 		out[name] = nameToBlock[name]
@@ -175,11 +243,17 @@ func AddRet(namesInOrder []string, nameToBlock map[string][]models.Instruction)
 	ret := "ret"
 	inst := models.Instruction{Op: &ret}
 	last := namesInOrder[len(namesInOrder)-1]
+	if len(out[last]) == 0 {
+		out[last] = append(out[last], inst)
+		return namesInOrder, out, nil
+	}
+	// A block that's only a label would fall off the end too, one that
+	// ends in a jump never does and anything after the jump is dead.
 	lastInst := out[last][len(out[last])-1]
-	if lastInst.Op != nil && *lastInst.Op != ret {
+	if lastInst.Op == nil || !Contains(terminators[:], *lastInst.Op) {
 		out[last] = append(out[last], inst)
 	}
-	return namesInOrder, out
+	return namesInOrder, out, nil
 }
 
 func LabelNonEmptyBlocks(namesInOrder []string, nameToBlock map[string][]models.Instruction) ([]string, map[string][]models.Instruction) {
@@ -200,9 +274,11 @@ func LabelNonEmptyBlocks(namesInOrder []string, nameToBlock map[string][]models.
 	return namesInOrder, out
 }
 
+// OutputBlockNameToSet prints the set of every block, indented to go under
+// the name of its function
 func OutputBlockNameToSet(namesInOrder []string, nameToSet map[string]Set) {
 	for _, name := range namesInOrder {
-		fmt.Printf("%s:\n", name)
-		fmt.Printf("  %s\n", nameToSet[name])
+		fmt.Printf("  %s:\n", name)
+		fmt.Printf("    %s\n", nameToSet[name])
 	}
 }
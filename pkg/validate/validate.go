@@ -0,0 +1,311 @@
+// Package validate checks that a program is well formed: that every op has
+// the arguments, labels and functions it needs, that jumps and calls go
+// somewhere and that calls and returns agree with the function's signature.
+// Types are only compared where a variable is declared with a single type,
+// anything more needs the type checker.
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/models"
+	"aaronstgeorge.com/self-guided-cs-1620/pkg/text"
+)
+
+// FunctionIndex is the index given to diagnostics about a function rather
+// than one of its instructions.
+const FunctionIndex = -1
+
+// Diagnostic is a problem with the instruction at Index in Function.
+type Diagnostic struct {
+	Function    string
+	Index       int
+	Instruction models.Instruction
+	Message     string
+}
+
+func (d Diagnostic) String() string {
+	if d.Index == FunctionIndex {
+		return fmt.Sprintf("@%s: %s", d.Function, d.Message)
+	}
+	// a label already ends with a colon
+	inst := strings.TrimSuffix(text.Instruction(d.Instruction), ":")
+	return fmt.Sprintf("@%s:%d: %s: %s", d.Function, d.Index, inst, d.Message)
+}
+
+type result int
+
+const (
+	// effect ops have no destination
+	effect result = iota
+	// value ops have a destination and a type
+	value
+	// either is for call, whether it has a destination depends on the
+	// function called
+	either
+)
+
+// shape is what an op needs, a count of many means there's no fixed number
+type shape struct {
+	args, labels, funcs int
+	result              result
+}
+
+const many = -1
+
+var shapes = map[string]shape{
+	"const": {0, 0, 0, value},
+	"id":    {1, 0, 0, value},
+	"not":   {1, 0, 0, value},
+
+	"jmp":   {0, 1, 0, effect},
+	"br":    {1, 2, 0, effect},
+	"call":  {many, 0, 1, either},
+	"ret":   {many, 0, 0, effect},
+	"print": {many, 0, 0, effect},
+	"nop":   {0, 0, 0, effect},
+
+	"phi": {many, many, 0, value},
+
+	"alloc":  {1, 0, 0, value},
+	"free":   {1, 0, 0, effect},
+	"store":  {2, 0, 0, effect},
+	"load":   {1, 0, 0, value},
+	"ptradd": {2, 0, 0, value},
+}
+
+func init() {
+	for _, op := range []string{
+		"add", "sub", "mul", "div",
+		"eq", "lt", "gt", "le", "ge",
+		"and", "or",
+		"fadd", "fsub", "fmul", "fdiv",
+		"feq", "flt", "fgt", "fle", "fge",
+	} {
+		shapes[op] = shape{2, 0, 0, value}
+	}
+}
+
+// Program checks every function in prog, diagnostics are in program order.
+func Program(prog models.Program) []Diagnostic {
+	var diagnostics []Diagnostic
+	nameToFunction := make(map[string]models.Function)
+	for _, function := range prog.Functions {
+		if _, ok := nameToFunction[function.Name]; ok {
+			diagnostics = append(diagnostics, Diagnostic{
+				Function: function.Name,
+				Index:    FunctionIndex,
+				Message:  "duplicate function",
+			})
+			continue
+		}
+		nameToFunction[function.Name] = function
+	}
+
+	for _, function := range prog.Functions {
+		c := checker{function: function, nameToFunction: nameToFunction}
+		c.check()
+		diagnostics = append(diagnostics, c.diagnostics...)
+	}
+	return diagnostics
+}
+
+type checker struct {
+	function       models.Function
+	nameToFunction map[string]models.Function
+	// varToType has the type of every variable declared with only one
+	// type, the others are missing
+	varToType   map[string]string
+	diagnostics []Diagnostic
+}
+
+func (c *checker) report(index int, format string, a ...interface{}) {
+	d := Diagnostic{Function: c.function.Name, Index: index, Message: fmt.Sprintf(format, a...)}
+	if index != FunctionIndex {
+		d.Instruction = c.function.Instrs[index]
+	}
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+func (c *checker) check() {
+	c.declarations()
+
+	labels := make(map[string]bool)
+	for _, inst := range c.function.Instrs {
+		if inst.Label != nil {
+			labels[*inst.Label] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, inst := range c.function.Instrs {
+		if inst.Label != nil {
+			if seen[*inst.Label] {
+				c.report(i, "duplicate label")
+			}
+			seen[*inst.Label] = true
+			continue
+		}
+		if inst.Op == nil {
+			continue
+		}
+		s, ok := shapes[*inst.Op]
+		if !ok {
+			c.report(i, "unknown op %s", *inst.Op)
+			continue
+		}
+		c.checkShape(i, inst, s)
+		for _, label := range inst.Labels {
+			if !labels[label] {
+				c.report(i, "undefined label .%s", label)
+			}
+		}
+		switch *inst.Op {
+		case "call":
+			c.checkCall(i, inst)
+		case "ret":
+			c.checkRet(i, inst)
+		}
+	}
+
+	if c.function.Type != nil && fallsOffEnd(c.function) {
+		index := len(c.function.Instrs) - 1
+		if index < 0 {
+			index = FunctionIndex
+		}
+		c.report(index, "missing return of %s at the end of the function", c.function.Type)
+	}
+}
+
+// declarations finds the type of every variable from the function's
+// arguments and the instructions that assign to it.
+func (c *checker) declarations() {
+	c.varToType = make(map[string]string)
+	ambiguous := make(map[string]bool)
+	declare := func(name string, t *models.Type) {
+		if t == nil || ambiguous[name] {
+			return
+		}
+		if previous, ok := c.varToType[name]; ok && previous != t.String() {
+			delete(c.varToType, name)
+			ambiguous[name] = true
+			return
+		}
+		c.varToType[name] = t.String()
+	}
+	for _, arg := range c.function.Args {
+		declare(arg.Name, arg.Type)
+	}
+	for _, inst := range c.function.Instrs {
+		if inst.Dest != nil {
+			declare(*inst.Dest, inst.Type)
+		}
+	}
+}
+
+func (c *checker) checkShape(i int, inst models.Instruction, s shape) {
+	count := func(what string, found, want int) {
+		if want != many && found != want {
+			c.report(i, "%s takes %d %s, got %d", *inst.Op, want, plural(what, want), found)
+		}
+	}
+	count("argument", len(inst.Args), s.args)
+	count("label", len(inst.Labels), s.labels)
+	count("function", len(inst.Funcs), s.funcs)
+
+	switch *inst.Op {
+	case "ret":
+		if len(inst.Args) > 1 {
+			c.report(i, "ret takes at most 1 argument, got %d", len(inst.Args))
+		}
+	case "phi":
+		if len(inst.Args) != len(inst.Labels) {
+			c.report(i, "phi has %d %s and %d %s", len(inst.Args), plural("argument", len(inst.Args)), len(inst.Labels), plural("label", len(inst.Labels)))
+		}
+	case "const":
+		if inst.Value == nil {
+			c.report(i, "const has no value")
+		}
+	}
+
+	switch s.result {
+	case value:
+		if inst.Dest == nil {
+			c.report(i, "%s has no destination", *inst.Op)
+		} else if inst.Type == nil {
+			c.report(i, "%s has no type", *inst.Op)
+		}
+	case effect:
+		if inst.Dest != nil {
+			c.report(i, "%s can't have a destination", *inst.Op)
+		}
+	}
+}
+
+func (c *checker) checkCall(i int, inst models.Instruction) {
+	if len(inst.Funcs) != 1 {
+		return
+	}
+	callee, ok := c.nameToFunction[inst.Funcs[0]]
+	if !ok {
+		c.report(i, "undefined function @%s", inst.Funcs[0])
+		return
+	}
+
+	if len(inst.Args) != len(callee.Args) {
+		c.report(i, "@%s takes %d %s, got %d", callee.Name, len(callee.Args), plural("argument", len(callee.Args)), len(inst.Args))
+	} else {
+		for j, arg := range inst.Args {
+			if t, ok := c.varToType[arg]; ok && callee.Args[j].Type != nil && t != callee.Args[j].Type.String() {
+				c.report(i, "argument %d of @%s is %s, %s is %s", j+1, callee.Name, callee.Args[j].Type, arg, t)
+			}
+		}
+	}
+
+	switch {
+	case inst.Dest != nil && callee.Type == nil:
+		c.report(i, "@%s doesn't return a value", callee.Name)
+	case inst.Dest == nil && callee.Type != nil:
+		c.report(i, "the %s returned by @%s isn't assigned", callee.Type, callee.Name)
+	case inst.Dest != nil && inst.Type != nil && inst.Type.String() != callee.Type.String():
+		c.report(i, "@%s returns %s, not %s", callee.Name, callee.Type, inst.Type)
+	}
+}
+
+func (c *checker) checkRet(i int, inst models.Instruction) {
+	switch {
+	case c.function.Type == nil && len(inst.Args) != 0:
+		c.report(i, "@%s doesn't return a value", c.function.Name)
+	case c.function.Type != nil && len(inst.Args) == 0:
+		c.report(i, "missing return of %s", c.function.Type)
+	case c.function.Type != nil:
+		if t, ok := c.varToType[inst.Args[0]]; ok && t != c.function.Type.String() {
+			c.report(i, "@%s returns %s, %s is %s", c.function.Name, c.function.Type, inst.Args[0], t)
+		}
+	}
+}
+
+// fallsOffEnd - whether the last instruction of function lets control run
+// past the end
+func fallsOffEnd(function models.Function) bool {
+	if len(function.Instrs) == 0 {
+		return true
+	}
+	last := function.Instrs[len(function.Instrs)-1]
+	if last.Op == nil {
+		return true
+	}
+	switch *last.Op {
+	case "ret", "jmp", "br":
+		return false
+	}
+	return true
+}
+
+func plural(what string, n int) string {
+	if n == 1 {
+		return what
+	}
+	return what + "s"
+}
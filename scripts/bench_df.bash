@@ -0,0 +1,53 @@
+#!/bin/bash
+set -euo pipefail
+# Times the df analyses on a generated function with a large control flow
+# graph, a chain of N small loops, and the dominator analysis on the same
+# function. The number of transfer function calls each analysis needed is
+# printed too, it doesn't depend on the machine. Set DF and DOM to compare
+# against other builds.
+#
+# usage: scripts/bench_df.bash [N] [runs]
+
+make build
+
+N=${1:-1000}
+RUNS=${2:-5}
+DF=${DF:-bin/df}
+DOM=${DOM:-bin/dom}
+
+PROG=$(mktemp)
+trap 'rm -f "$PROG"' EXIT
+
+{
+  echo "@main(n: int) {"
+  echo "  one: int = const 1;"
+  for ((i = 0; i < N; i++)); do
+    echo ".h$i:"
+    echo "  v$i: int = add n one;"
+    echo "  c$i: bool = lt v$i n;"
+    echo "  br c$i .b$i .h$((i + 1));"
+    echo ".b$i:"
+    echo "  n: int = sub n one;"
+    echo "  jmp .h$i;"
+  done
+  echo ".h$N:"
+  echo "  print n;"
+  echo "}"
+} | bin/bril2json >"$PROG"
+
+for analysis in defined live reaching available busy; do
+  start=$(date +%s%N)
+  for ((r = 0; r < RUNS; r++)); do
+    "$DF" "$analysis" <"$PROG" >/dev/null
+  done
+  end=$(date +%s%N)
+  calls=$("$DF" -stats "$analysis" <"$PROG" | sed -n 's/^transfer calls: //p')
+  echo "$analysis: $(((end - start) / RUNS / 1000000)) ms, $calls transfer calls"
+done
+
+start=$(date +%s%N)
+for ((r = 0; r < RUNS; r++)); do
+  "$DOM" dom <"$PROG" >/dev/null
+done
+end=$(date +%s%N)
+echo "dom: $(((end - start) / RUNS / 1000000)) ms"
@@ -13,7 +13,7 @@ import (
 
 const newTestsPath = "/home/aaron/Dev/go/src/github.com/AaronStGeorge/cs-6120/test/df"
 const originalTestsPath = "/home/aaron/Dev/misc/bril/examples/test/df"
-const turntToml = "command = \"bril2json < {filename} | ../../bin/df {args}\""
+const turntToml = "command = \"../../bin/bril2json < {filename} | ../../bin/df {args}\""
 
 func main() {
 	err := os.RemoveAll(newTestsPath)
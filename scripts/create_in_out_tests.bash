@@ -29,5 +29,5 @@ echo "Saving output of bril2json"
 turnt ./*.bril --save || true
 
 echo "Writing real turnt.toml"
-echo "command = \"bril2json < {filename} | ../../bin/in-out | jq\"" > turnt.toml
+echo "command = \"../../bin/bril2json < {filename} | ../../bin/in-out | jq\"" > turnt.toml
 turnt ./*.bril || true
@@ -9,7 +9,7 @@ BRIL_TEST_DIR=${BRIL_TEST_DIR:-~/Dev/misc/bril/test}
 
 # TODO document what this find command does
 for i in $(find $BRIL_TEST_DIR -type f -name "*.bril" ! -name "spec*" ! -name "ssa*"); do
-  if bril2json <"$i" | "./$1" >/dev/null; then
+  if bin/bril2json <"$i" | "./$1" >/dev/null; then
     echo "ok - $i"
   else
     echo "fail - $i"